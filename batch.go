@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BatchConcurrency - max number of cities fetched at the same time with -cities-file
+const BatchConcurrency = 4
+
+// retryBudget - number of retries left for all cities with -max-retries-total, nil - without limit
+type retryBudget struct {
+	mu   sync.Mutex
+	left int
+}
+
+//-----------------------------------------------------------------------------
+// take one retry from the budget, false if it is exhausted
+func (budget *retryBudget) take() bool {
+	if budget == nil {
+		return true
+	}
+
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	if budget.left <= 0 {
+		return false
+	}
+	budget.left--
+
+	return true
+}
+
+// batchResult - weather or error for one city from -cities-file
+type batchResult struct {
+	Query string `json:"query"`
+	Error string `json:"error,omitempty"`
+	*ForecastJSON
+
+	city            string
+	forecastNow     map[string]interface{}
+	forecastByHours []HourTemp
+	forecastNext    []DayForecast
+}
+
+//-----------------------------------------------------------------------------
+// parse list of cities: one city on each line, skip empty lines and "#" comments
+func parseCitiesFile(content string) []string {
+	result := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			result = append(result, line)
+		}
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get config for city from the list: with city slug from URL, alias or name
+func batchCityConfig(city string, aliases map[string]string, cfg Config) Config {
+	cityCfg := cfg
+	cityCfg.city = normalizeCity(citySlug(city, cfg.baseURL))
+	if aliasCity, ok := aliases[city]; ok {
+		cityCfg.city = aliasCity
+	} else if aliasCity, ok := aliases[cityCfg.city]; ok {
+		cityCfg.city = aliasCity
+	}
+	cityCfg.cityName, cityCfg.city = cityCfg.city, normalizeCitySlug(cityCfg.city)
+
+	return cityCfg
+}
+
+//-----------------------------------------------------------------------------
+// get weather for each city from the list, concurrently but not more than BatchConcurrency at once
+func getWeatherBatch(ctx context.Context, cities []string, cfg Config) []batchResult {
+	aliases := getAliases()
+	results := make([]batchResult, len(cities))
+	semaphore := make(chan struct{}, BatchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, city := range cities {
+		wg.Add(1)
+		go func(i int, city string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			cityCfg := batchCityConfig(city, aliases, cfg)
+			result := batchResult{Query: city, city: cityCfg.city}
+			forecastNow, forecastByHours, forecastNext, err := getWeatherNotEmpty(ctx, cityCfg)
+			switch {
+			case err != nil:
+				result.Error = err.Error()
+			case stringValue(forecastNow, "city") == "":
+				result.Error = fmt.Sprintf("city %q not found", cityCfg.city)
+			default:
+				forecastJSON := getForecastJSON(forecastNow, forecastByHours, forecastNext, cityCfg)
+				result.ForecastJSON = &forecastJSON
+				result.forecastNow, result.forecastByHours, result.forecastNext = forecastNow, forecastByHours, forecastNext
+			}
+			results[i] = result
+		}(i, city)
+	}
+	wg.Wait()
+
+	return results
+}
+
+//-----------------------------------------------------------------------------
+// get weather for all cities from file, print JSON array or text report, return exit code
+func runBatch(ctx context.Context, cfg Config) int {
+	content, err := ioutil.ReadFile(cfg.citiesFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	exitCode := 0
+	results := getWeatherBatch(ctx, parseCitiesFile(string(content)), cfg)
+	for _, result := range results {
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", result.Query, result.Error)
+			exitCode = 1
+		}
+	}
+
+	if cfg.getJSON {
+		fmt.Println(string(cfg.marshalJSON(results)))
+		return exitCode
+	}
+
+	cityCfg := cfg
+	cityCfg.alsoJSON = ""
+	separator := ""
+	for _, result := range results {
+		if result.Error != "" {
+			continue
+		}
+		fmt.Print(separator)
+		// header of forecast table only for the first city, if not -repeat-header
+		cityCfg.noHeader = cfg.noHeader || separator != "" && !cfg.repeatHeader
+		separator = "\n"
+		cityCfg.city = result.city
+		if err := render(result.forecastNow, result.forecastByHours, result.forecastNext, cityCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", result.Query, err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+//-----------------------------------------------------------------------------
+// print URLs and headers of requests for city or for each city from -cities-file, without requests
+func runDryRun(writer io.Writer, cfg Config) int {
+	configs := []Config{cfg}
+	if cfg.citiesFile != "" {
+		content, err := ioutil.ReadFile(cfg.citiesFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		aliases := getAliases()
+		configs = []Config{}
+		for _, city := range parseCitiesFile(string(content)) {
+			configs = append(configs, batchCityConfig(city, aliases, cfg))
+		}
+	}
+
+	headers := []string{}
+	for key, values := range requestHeaders(cfg) {
+		for _, value := range values {
+			headers = append(headers, key+": "+value)
+		}
+	}
+	sort.Strings(headers)
+
+	for i, cityCfg := range configs {
+		if i > 0 {
+			fmt.Fprintln(writer)
+		}
+		if cfg.htmlFile != "" {
+			fmt.Fprintf(writer, "file://%s\n", cfg.htmlFile)
+			continue
+		}
+
+		urls := []string{cityCfg.baseURL + cityCfg.city}
+		if !cfg.noToday {
+			urls = append(urls, cityCfg.baseURLMini+cityCfg.city)
+		}
+		for _, pageURL := range urls {
+			fmt.Fprintf(writer, "GET %s\n", pageURL)
+			for _, header := range headers {
+				fmt.Fprintf(writer, "  %s\n", header)
+			}
+		}
+	}
+
+	return 0
+}
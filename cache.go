@@ -0,0 +1,224 @@
+// cache of the last successful result, for offline display
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// CacheDirName - directory for cache in user cache directory
+	CacheDirName = "yandex-weather-cli"
+	// PressureTrendMinAge - minimum age of saved pressure for -pressure-trend, newer ones differ only by rounding
+	PressureTrendMinAge = time.Hour
+	// PressureTrendMaxAge - maximum age of saved pressure for -pressure-trend
+	PressureTrendMaxAge = 12 * time.Hour
+	// PressureReadingInterval - minimum interval between saved pressures, for frequent runs (e.g. from cron)
+	PressureReadingInterval = 10 * time.Minute
+)
+
+// cachedWeather - the last successful result
+type cachedWeather struct {
+	Time        time.Time              `json:"time"`
+	ForecastNow map[string]interface{} `json:"forecast_now"`
+	ByHours     []HourTemp             `json:"by_hours"`
+	NextDays    []DayForecast          `json:"next_days"`
+	Pressures   []pressureReading      `json:"pressures,omitempty"`
+}
+
+// pressureReading - pressure at the time, for -pressure-trend
+type pressureReading struct {
+	Time     time.Time `json:"time"`
+	Pressure string    `json:"pressure"`
+}
+
+// cachedPage - the last page with validators for conditional GET
+type cachedPage struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Body         string `json:"body"`
+}
+
+//-----------------------------------------------------------------------------
+// get cache file name for city
+func getCacheFileName(city string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	if city == "" {
+		city = "_default"
+	}
+
+	return filepath.Join(cacheDir, CacheDirName, url.QueryEscape(city)+".json"), nil
+}
+
+//-----------------------------------------------------------------------------
+// save weather for city to cache, with saved pressures of the last hours
+func saveCache(city string, forecastNow map[string]interface{}, forecastByHours []HourTemp, forecastNext []DayForecast, pressures []pressureReading) error {
+	fileName, err := getCacheFileName(city)
+	if err != nil {
+		return err
+	}
+
+	return writeCacheFile(fileName, cachedWeather{
+		Time:        time.Now(),
+		ForecastNow: forecastNow,
+		ByHours:     forecastByHours,
+		NextDays:    forecastNext,
+		Pressures:   pressures,
+	})
+}
+
+//-----------------------------------------------------------------------------
+// load weather for city from cache
+func loadCache(city string) (cachedWeather, error) {
+	fileName, err := getCacheFileName(city)
+	if err != nil {
+		return cachedWeather{}, err
+	}
+
+	return readCacheFile(fileName)
+}
+
+//-----------------------------------------------------------------------------
+// get pressure trend by the newest saved pressure which is older than PressureTrendMinAge:
+// "rising", "falling", or empty if it is not changed or there is no such pressure
+func pressureTrendFromCache(forecastNow map[string]interface{}, cached cachedWeather, now time.Time) string {
+	current := stringValue(forecastNow, "pressure")
+	if current == "" {
+		return ""
+	}
+
+	for i := len(cached.Pressures) - 1; i >= 0; i-- {
+		reading := cached.Pressures[i]
+		if age := now.Sub(reading.Time); age < PressureTrendMinAge || age > PressureTrendMaxAge || reading.Pressure == "" {
+			continue
+		}
+
+		switch diff := convertStrToInt(current) - convertStrToInt(reading.Pressure); {
+		case diff > 0:
+			return "rising"
+		case diff < 0:
+			return "falling"
+		}
+		return ""
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// add current pressure to saved ones, not more often than PressureReadingInterval,
+// pressures older than PressureTrendMaxAge are removed
+func addPressureReading(pressures []pressureReading, pressure string, now time.Time) []pressureReading {
+	result := []pressureReading{}
+	for _, reading := range pressures {
+		if now.Sub(reading.Time) <= PressureTrendMaxAge {
+			result = append(result, reading)
+		}
+	}
+
+	if pressure != "" && (len(result) == 0 || now.Sub(result[len(result)-1].Time) >= PressureReadingInterval) {
+		result = append(result, pressureReading{Time: now, Pressure: pressure})
+	}
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get cache file name for page by URL
+func getPageCacheFileName(pageURL string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, CacheDirName, "pages", url.QueryEscape(pageURL)+".json"), nil
+}
+
+//-----------------------------------------------------------------------------
+// save page with ETag/Last-Modified to cache, pages without them are not saved
+func savePageCache(pageURL string, page cachedPage) error {
+	if page.ETag == "" && page.LastModified == "" {
+		return nil
+	}
+
+	fileName, err := getPageCacheFileName(pageURL)
+	if err != nil {
+		return err
+	}
+
+	return writeCacheFile(fileName, page)
+}
+
+//-----------------------------------------------------------------------------
+// load page from cache by URL
+func loadPageCache(pageURL string) (cachedPage, error) {
+	page := cachedPage{}
+	fileName, err := getPageCacheFileName(pageURL)
+	if err != nil {
+		return page, err
+	}
+
+	content, err := ioutil.ReadFile(fileName) // #nosec
+	if err != nil {
+		return page, err
+	}
+
+	err = json.Unmarshal(content, &page)
+	return page, err
+}
+
+//-----------------------------------------------------------------------------
+func writeCacheFile(fileName string, cached interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file and rename, so an interrupted write doesn't leave truncated cache
+	tmpFileName := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmpFileName, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFileName, fileName)
+}
+
+//-----------------------------------------------------------------------------
+func readCacheFile(fileName string) (cachedWeather, error) {
+	cached := cachedWeather{}
+
+	content, err := ioutil.ReadFile(fileName) // #nosec
+	if err != nil {
+		return cached, err
+	}
+
+	if err := json.Unmarshal(content, &cached); err != nil {
+		return cached, err
+	}
+
+	// numbers from JSON are float64, but forecast has int values
+	for name, value := range cached.ForecastNow {
+		if number, ok := value.(float64); ok {
+			cached.ForecastNow[name] = int(number)
+		}
+	}
+
+	// human date is not saved in JSON
+	for i, day := range cached.NextDays {
+		if date, err := time.Parse("2006-01-02", day.Date); err == nil {
+			cached.NextDays[i].DateHuman, _ = formatDates(date)
+		}
+	}
+
+	return cached, nil
+}
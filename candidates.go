@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/msoap/html2data"
+)
+
+const (
+	// PickerMaxCandidates - maximum number of found cities in list of -interactive picker
+	PickerMaxCandidates = 10
+	// PickerMinQuery - minimum length of city name for search in -interactive picker
+	PickerMinQuery = 2
+	// PickerPrompt - prompt of city name for -interactive
+	PickerPrompt = "Город (пусто - по местоположению): "
+)
+
+// pickerKey - key pressed in -interactive picker
+type pickerKey int
+
+const (
+	keyNone pickerKey = iota
+	keyText
+	keyUp
+	keyDown
+	keyEnter
+	keyBackspace
+	keyCancel
+)
+
+// SelectorsCityCandidates - css selectors for list of cities on the page of search, when several cities are found by name
+var SelectorsCityCandidates = map[string]string{
+	"name":   "ul.place-list li.place-list__item a.place-list__item-name",
+	"href":   "ul.place-list li.place-list__item a.place-list__item-name:attr(href)",
+	"region": "ul.place-list li.place-list__item span.place-list__item-region-name",
+}
+
+// CityCandidate - one of cities found by ambiguous name
+type CityCandidate struct {
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	Slug   string `json:"slug"`
+}
+
+// CandidatesJSON - found cities for JSON output, with status "ambiguous"
+type CandidatesJSON struct {
+	Status     string          `json:"status"`
+	City       string          `json:"city"`
+	Candidates []CityCandidate `json:"candidates"`
+}
+
+// cityCandidatesError - error about several cities found by name
+type cityCandidatesError struct {
+	city       string
+	candidates []CityCandidate
+}
+
+func (e cityCandidatesError) Error() string {
+	slugs := []string{}
+	for _, candidate := range e.candidates {
+		slugs = append(slugs, candidate.Slug)
+	}
+	return fmt.Sprintf("found several cities for %q: %s", e.city, strings.Join(slugs, ", "))
+}
+
+//-----------------------------------------------------------------------------
+// get list of cities from the page of search, empty if it is a page of weather
+func getCityCandidates(doc html2data.Doc) []CityCandidate {
+	if termNow, err := doc.GetDataSingle(Selectors["term_now"]); err != nil || strings.TrimSpace(termNow) != "" {
+		return nil
+	}
+
+	data, err := doc.GetData(SelectorsCityCandidates)
+	if err != nil || len(data["name"]) != len(data["href"]) {
+		return nil
+	}
+
+	result := []CityCandidate{}
+	for i, name := range data["name"] {
+		slug := candidateSlug(data["href"][i])
+		if slug == "" {
+			continue
+		}
+
+		candidate := CityCandidate{Name: clearNonprintInString(name), Slug: slug}
+		// region can be absent for some cities, then it is not clear to which city it belongs
+		if len(data["region"]) == len(data["name"]) {
+			candidate.Region = clearNonprintInString(data["region"][i])
+		}
+		result = append(result, candidate)
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get city slug from link to weather page: "/pogoda/troitsk?via=srp" -> "troitsk"
+func candidateSlug(href string) string {
+	parsedURL, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimPrefix(parsedURL.Path, "/pogoda/"), "/")
+}
+
+//-----------------------------------------------------------------------------
+// get name of city with region and slug: "Троицк, Москва (troitsk)"
+func (candidate CityCandidate) title() string {
+	name := candidate.Name
+	if candidate.Region != "" {
+		name += ", " + candidate.Region
+	}
+	return name + " (" + candidate.Slug + ")"
+}
+
+//-----------------------------------------------------------------------------
+// print numbered list of found cities
+func renderCandidates(writer io.Writer, candidates []CityCandidate) {
+	for i, candidate := range candidates {
+		fmt.Fprintf(writer, "%2d. %s\n", i+1, candidate.title())
+	}
+}
+
+//-----------------------------------------------------------------------------
+// print list of found cities and ask for number of city, false if nothing is chosen
+func chooseCandidate(reader io.Reader, writer io.Writer, candidates []CityCandidate) (CityCandidate, bool) {
+	renderCandidates(writer, candidates)
+	fmt.Fprintf(writer, "Номер города (1-%d): ", len(candidates))
+
+	line, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil && line == "" {
+		return CityCandidate{}, false
+	}
+
+	number, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || number < 1 || number > len(candidates) {
+		return CityCandidate{}, false
+	}
+
+	return candidates[number-1], true
+}
+
+//-----------------------------------------------------------------------------
+// ask city name and choose city from found by Yandex search, for -interactive,
+// empty name - city by location, false if nothing is chosen
+func pickCity(ctx context.Context, reader io.Reader, writer io.Writer, cfg Config) (string, bool) {
+	bufReader := bufio.NewReader(reader)
+	for {
+		fmt.Fprint(writer, PickerPrompt)
+		line, err := bufReader.ReadString('\n')
+		query := strings.TrimSpace(line)
+		switch {
+		case err != nil && query == "":
+			return "", false
+		case query == "":
+			return "", true
+		}
+
+		candidates, err := searchCities(ctx, query, cfg)
+		if err != nil {
+			fmt.Fprintln(writer, err)
+			continue
+		}
+
+		switch len(candidates) {
+		case 0:
+			fmt.Fprintf(writer, "Город %q не найден\n", query)
+		case 1:
+			return candidates[0].Slug, true
+		default:
+			if candidate, ok := chooseCandidate(bufReader, writer, candidates); ok {
+				return candidate.Slug, true
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// find cities by name with Yandex search
+func searchCities(ctx context.Context, query string, cfg Config) ([]CityCandidate, error) {
+	doc, finalURL := getPage(ctx, cfg.baseURL+"search?request="+url.QueryEscape(query), cfg)
+	if doc.Err != nil {
+		return nil, doc.Err
+	}
+
+	if termNow, err := doc.GetDataSingle(Selectors["term_now"]); err == nil && strings.TrimSpace(termNow) != "" {
+		// search is redirected to the page of the only found city
+		return []CityCandidate{{Name: query, Slug: citySlug(finalURL, cfg.baseURL)}}, nil
+	}
+
+	return getCityCandidates(doc), nil
+}
+
+//-----------------------------------------------------------------------------
+// choose city in raw terminal for -interactive: cities are searched while name is typed,
+// city is chosen by arrow keys and Enter, empty name - city by location, false if it is cancelled
+func selectCity(ctx context.Context, reader io.Reader, writer io.Writer, cfg Config) (string, bool) {
+	keys := bufio.NewReader(reader)
+	query := []rune{}
+	candidates := []CityCandidate{}
+	selected, status, needSearch := 0, "", false
+
+	for {
+		// typed keys are not read yet (e.g. pasted name), search after them
+		if needSearch && keys.Buffered() == 0 {
+			candidates, selected, status, needSearch = nil, 0, "", false
+			if len(query) >= PickerMinQuery {
+				drawPicker(writer, string(query), nil, 0, "поиск...", cfg)
+				found, err := searchCities(ctx, string(query), cfg)
+				switch {
+				case err != nil:
+					status = err.Error()
+				case len(found) == 0:
+					status = fmt.Sprintf("Город %q не найден", string(query))
+				case len(found) > PickerMaxCandidates:
+					found = found[:PickerMaxCandidates]
+				}
+				candidates = found
+			}
+		}
+		drawPicker(writer, string(query), candidates, selected, status, cfg)
+
+		key, char, err := readPickerKey(keys)
+		if err != nil || ctx.Err() != nil {
+			key = keyCancel
+		}
+
+		switch key {
+		case keyText:
+			query = append(query, char)
+			needSearch = true
+		case keyBackspace:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				needSearch = true
+			}
+		case keyUp:
+			if selected > 0 {
+				selected--
+			}
+		case keyDown:
+			if selected < len(candidates)-1 {
+				selected++
+			}
+		case keyEnter:
+			switch {
+			case needSearch:
+				// Enter after pasted name, the list is not updated yet
+			case len(query) == 0:
+				fmt.Fprint(writer, "\r\033[J"+PickerPrompt+"\n")
+				return "", true
+			case len(candidates) > 0:
+				fmt.Fprint(writer, "\r\033[J"+PickerPrompt+candidates[selected].title()+"\n")
+				return candidates[selected].Slug, true
+			}
+		case keyCancel:
+			fmt.Fprint(writer, "\r\033[J"+PickerPrompt+"\n")
+			return "", false
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// draw prompt with typed name and list of found cities under it, cursor is left after the name
+func drawPicker(writer io.Writer, query string, candidates []CityCandidate, selected int, status string, cfg Config) {
+	width := getTerminalWidth()
+	lines := []string{}
+	for i, candidate := range candidates {
+		line := truncateString("  "+candidate.title(), width-1)
+		if i == selected {
+			line = cfg.ansiColourString("<value>" + truncateString("> "+candidate.title(), width-1) + "</>")
+		}
+		lines = append(lines, line)
+	}
+	if status != "" {
+		lines = append(lines, truncateString(status, width-1))
+	}
+
+	prompt := PickerPrompt + query
+	fmt.Fprint(writer, "\r\033[J"+prompt)
+	if len(lines) > 0 {
+		fmt.Fprintf(writer, "\n%s\033[%dA\r%s", strings.Join(lines, "\n"), len(lines), prompt)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// read one key in raw terminal: text, arrows (or Ctrl-P/Ctrl-N), Enter, Backspace, Ctrl-C/Ctrl-D
+func readPickerKey(keys *bufio.Reader) (pickerKey, rune, error) {
+	char, _, err := keys.ReadRune()
+	if err != nil {
+		return keyNone, 0, err
+	}
+
+	switch char {
+	case '\r', '\n':
+		return keyEnter, 0, nil
+	case 127, '\b':
+		return keyBackspace, 0, nil
+	case 3, 4:
+		return keyCancel, 0, nil
+	case 16:
+		return keyUp, 0, nil
+	case 14:
+		return keyDown, 0, nil
+	case 27:
+		// escape sequence of arrow: "ESC [ A" or "ESC O A"
+		if next, _, err := keys.ReadRune(); err != nil || next != '[' && next != 'O' {
+			return keyNone, 0, err
+		}
+		code, _, err := keys.ReadRune()
+		switch {
+		case err != nil:
+			return keyNone, 0, err
+		case code == 'A':
+			return keyUp, 0, nil
+		case code == 'B':
+			return keyDown, 0, nil
+		}
+		return keyNone, 0, nil
+	}
+
+	if unicode.IsPrint(char) {
+		return keyText, char, nil
+	}
+	return keyNone, 0, nil
+}
@@ -0,0 +1,143 @@
+// health check of parsing: all critical selectors should be found on the page
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/msoap/html2data"
+)
+
+// OptionalSelectors - selectors which can be missing on the page
+var OptionalSelectors = map[string]bool{
+	"pressure_trend": true,
+	"local_time":     true,
+	"wind_gust":      true,
+	"feels_now":      true,
+	"observed_at":    true,
+	"temp_feels":     true,
+}
+
+// selectorStat - result of one selector search on the page
+type selectorStat struct {
+	name   string
+	count  int
+	sample string
+}
+
+//-----------------------------------------------------------------------------
+// get count of not empty values and sample value for each selector, sorted by name
+func getSelectorsStat(doc html2data.Doc, selectors map[string]string) ([]selectorStat, error) {
+	data, err := doc.GetData(selectors)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []selectorStat{}
+	for name := range selectors {
+		stat := selectorStat{name: name}
+		for _, value := range data[name] {
+			if value = clearNonprintInString(value); value != "" {
+				if stat.count == 0 {
+					stat.sample = value
+				}
+				stat.count++
+			}
+		}
+		result = append(result, stat)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+
+	return result, nil
+}
+
+//-----------------------------------------------------------------------------
+// get names of critical selectors with empty values after parsing, for -strict
+func getEmptyCriticalSelectors(forecastNow map[string]interface{}) []string {
+	result := []string{}
+	for _, name := range stringsValue(forecastNow, "selectors_empty") {
+		if !OptionalSelectors[strings.TrimPrefix(name, "next_days.")] {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get names of critical selectors which are not found on the page
+func getMissingSelectors(doc html2data.Doc) ([]string, error) {
+	missing := []string{}
+	for _, selectors := range []map[string]string{Selectors, SelectorsNextDays} {
+		stats, err := getSelectorsStat(doc, selectors)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, stat := range stats {
+			if stat.count == 0 && !OptionalSelectors[stat.name] {
+				missing = append(missing, stat.name)
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+//-----------------------------------------------------------------------------
+// check parsing of the page, print one line status and return exit code
+func runCheck(ctx context.Context, cfg Config) int {
+	doc, sourceURL := getPage(ctx, cfg.baseURL+cfg.city, cfg)
+	missing, err := getMissingSelectors(doc)
+	switch {
+	case err != nil:
+		fmt.Printf("FAIL: %s: %s\n", sourceURL, err)
+		return 1
+	case len(missing) > 0:
+		fmt.Printf("FAIL: %s: selectors not found: %s\n", sourceURL, strings.Join(missing, ", "))
+		return 1
+	}
+
+	fmt.Printf("OK: %s\n", sourceURL)
+	return 0
+}
+
+//-----------------------------------------------------------------------------
+// print all selectors with count of found values and sample value, return exit code as runCheck
+func runCheckSelectors(ctx context.Context, cfg Config) int {
+	doc, sourceURL := getPage(ctx, cfg.baseURL+cfg.city, cfg)
+	fmt.Println(sourceURL)
+
+	exitCode := 0
+	for _, group := range []struct {
+		name      string
+		selectors map[string]string
+	}{
+		{"Selectors", Selectors},
+		{"SelectorsNextDays", SelectorsNextDays},
+	} {
+		stats, err := getSelectorsStat(doc, group.selectors)
+		if err != nil {
+			fmt.Printf("FAIL: %s: %s\n", sourceURL, err)
+			return 1
+		}
+
+		fmt.Printf("%s:\n", group.name)
+		for _, stat := range stats {
+			mark, sample := "✓", truncateString(stat.sample, 40)
+			if stat.count == 0 {
+				mark = "✗"
+				if OptionalSelectors[stat.name] {
+					sample = "(optional)"
+				} else {
+					sample, exitCode = "(not found)", 1
+				}
+			}
+			fmt.Printf("  %s %-20s %3d  %s\n", mark, stat.name, stat.count, sample)
+		}
+	}
+
+	return exitCode
+}
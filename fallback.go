@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvBaseURLWttrName - environment variable for setup base URL of wttr.in fallback source
+	EnvBaseURLWttrName = "Y_WEATHER_WTTR_URL"
+	// BaseURLWttrDefault - wttr.in service url
+	BaseURLWttrDefault = "https://wttr.in/"
+)
+
+// weatherSource - fetch and parse weather for city, in the same structures as for Yandex page
+type weatherSource func(ctx context.Context, cfg Config) (map[string]interface{}, []HourTemp, []DayForecast, error)
+
+// FallbackSources - alternate weather sources for -fallback, used when Yandex is unavailable
+var FallbackSources = map[string]weatherSource{
+	"wttr": getWeatherWttr,
+}
+
+// WindDirectionsRu - wind directions from north clockwise
+var WindDirectionsRu = [...]string{"С", "СВ", "В", "ЮВ", "Ю", "ЮЗ", "З", "СЗ"}
+
+// wttrValue - value in wttr.in JSON: [{"value": "..."}]
+type wttrValue []struct {
+	Value string `json:"value"`
+}
+
+// wttrResponse - part of wttr.in JSON (format=j1) used for weather
+type wttrResponse struct {
+	CurrentCondition []struct {
+		TempC         string    `json:"temp_C"`
+		Humidity      string    `json:"humidity"`
+		Pressure      string    `json:"pressure"`
+		WindspeedKmph string    `json:"windspeedKmph"`
+		WinddirDegree string    `json:"winddirDegree"`
+		LangRu        wttrValue `json:"lang_ru"`
+	} `json:"current_condition"`
+	NearestArea []struct {
+		AreaName wttrValue `json:"areaName"`
+	} `json:"nearest_area"`
+	Weather []struct {
+		Date     string `json:"date"`
+		MaxtempC string `json:"maxtempC"`
+		MintempC string `json:"mintempC"`
+		Hourly   []struct {
+			Time   string    `json:"time"`
+			LangRu wttrValue `json:"lang_ru"`
+		} `json:"hourly"`
+	} `json:"weather"`
+}
+
+//-----------------------------------------------------------------------------
+// get first value or empty string
+func (value wttrValue) String() string {
+	if len(value) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(value[0].Value)
+}
+
+//-----------------------------------------------------------------------------
+// get wttr.in base URL from environment or default
+func getBaseURLWttr() string {
+	if baseURL := os.Getenv(EnvBaseURLWttrName); len(baseURL) > 0 {
+		return baseURL
+	}
+	return BaseURLWttrDefault
+}
+
+//-----------------------------------------------------------------------------
+// get weather from wttr.in, forecast by hours is not filled
+func getWeatherWttr(ctx context.Context, cfg Config) (map[string]interface{}, []HourTemp, []DayForecast, error) {
+	location := cfg.city
+	if cfg.coords != "" {
+		// wttr.in gets coordinates as is: "55.75,37.62"
+		location = strings.Replace(cfg.coords, " ", "", -1)
+	}
+	sourceURL := getBaseURLWttr() + url.PathEscape(location) + "?format=j1&lang=ru"
+	cfg.logVerbose("fallback: GET %s", sourceURL)
+
+	request, err := http.NewRequestWithContext(ctx, "GET", sourceURL, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	request.Header.Set("User-Agent", userAgent)
+
+	client := &http.Client{Timeout: cfg.timeout, Transport: getTransport(cfg)}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, nil, nil, friendlyNetworkError(err, cfg)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, nil, nil, fmt.Errorf("%s: %s", sourceURL, response.Status)
+	}
+
+	wttr := wttrResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&wttr); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse wttr.in response: %s", err)
+	}
+
+	forecastNow, forecastNext, err := parseWttr(wttr, cfg, time.Now())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	forecastNow["source_url"] = sourceURL
+
+	return forecastNow, []HourTemp{}, forecastNext, nil
+}
+
+//-----------------------------------------------------------------------------
+// map wttr.in JSON to current weather and forecast for next days, as from Yandex page,
+// forecast for today is skipped as on Yandex page
+func parseWttr(wttr wttrResponse, cfg Config, now time.Time) (map[string]interface{}, []DayForecast, error) {
+	if len(wttr.CurrentCondition) == 0 {
+		return nil, nil, fmt.Errorf("wttr.in response has no current weather")
+	}
+	current := wttr.CurrentCondition[0]
+
+	city := cfg.city
+	if len(wttr.NearestArea) > 0 && wttr.NearestArea[0].AreaName.String() != "" {
+		city = wttr.NearestArea[0].AreaName.String()
+	}
+
+	forecastNow := map[string]interface{}{
+		"city":     city,
+		"term_now": convertStrToInt(current.TempC),
+		"desc_now": current.LangRu.String(),
+	}
+	if current.Humidity != "" {
+		forecastNow["humidity"] = current.Humidity + "%"
+	}
+	if current.Pressure != "" {
+		// hPa -> mm Hg
+		forecastNow["pressure"] = fmt.Sprintf("%d мм рт. ст.", int(math.Round(convertStrToFloat(current.Pressure)*0.750062)))
+	}
+	if current.WindspeedKmph != "" {
+		speed := convertStrToFloat(current.WindspeedKmph) / 3.6
+		wind := strings.Replace(fmt.Sprintf("%.1f м/с", speed), ".", ",", 1)
+		if current.WinddirDegree != "" && speed > 0 {
+			degree := convertStrToFloat(current.WinddirDegree)
+			wind += ", " + WindDirectionsRu[int(math.Round(degree/45))%len(WindDirectionsRu)]
+		}
+		forecastNow["wind"] = wind
+	}
+
+	forecastNext := []DayForecast{}
+	for _, day := range wttr.Weather {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err != nil || day.Date <= now.Format("2006-01-02") {
+			continue
+		}
+
+		desc := ""
+		for _, hour := range day.Hourly {
+			if hour.Time == "1200" {
+				desc = strings.ToLower(hour.LangRu.String())
+			}
+		}
+
+		dayForecast := DayForecast{
+			Desc:      desc,
+			Temp:      convertStrToInt(day.MaxtempC),
+			TempNight: convertStrToInt(day.MintempC),
+		}
+		dayForecast.DateHuman, dayForecast.Date = formatDates(date)
+		forecastNext = append(forecastNext, dayForecast)
+	}
+
+	if len(forecastNext) > cfg.skipDays+cfg.daysLimit {
+		forecastNext = forecastNext[:cfg.skipDays+cfg.daysLimit]
+	}
+
+	forecastNow["next_days_on_page"] = len(forecastNext) > 0
+	if !cfg.allDays {
+		forecastNext = filterDays(forecastNext, cfg, now)
+	}
+
+	return forecastNow, forecastNext, nil
+}
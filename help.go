@@ -0,0 +1,126 @@
+// documentation of all options by groups for -help-all
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// flagGroup - group of options with examples, "%s" in examples is the name of program
+type flagGroup struct {
+	title    string
+	flags    []string
+	examples []string
+}
+
+// FlagGroups - groups of options for -help-all, options without group are shown in the last one
+var FlagGroups = []flagGroup{
+	{
+		title:    "Current weather",
+		flags:    []string{"no-current", "no-today", "no-pressure", "pressure-trend", "no-humidity", "no-wind", "comfort", "dewpoint", "brief"},
+		examples: []string{"%s -no-today -comfort -dewpoint london", "%s -brief london"},
+	},
+	{
+		title: "Forecast for next days",
+		flags: []string{
+			"days", "skip", "columns", "forecast-format", "temps-only", "feels", "no-header", "max-width", "min-width",
+			"sep", "weekday-style", "swap-day-night", "sparkline", "summary", "avg", "weekends",
+		},
+		examples: []string{"%s -skip 2 -days 5 london", "%s -columns date,temp,temp_night -sep ';' london", "%s -no-current -no-today -temps-only london"},
+	},
+	{
+		title:    "Text output",
+		flags:    []string{"no-color", "color-when-piped", "theme", "color-test", "celsius-symbol", "unicode-minus", "no-link", "ascii", "transliterate", "template"},
+		examples: []string{"%s -theme light -unicode-minus london", `%s -template '{{.City}}: {{color "value" .TermNow}}' london`},
+	},
+	{
+		title:    "JSON and other formats",
+		flags:    []string{"json", "json-indent", "json-array-always", "json-flat", "compact-json", "also-json", "append", "snapshot", "meta", "precision", "prometheus"},
+		examples: []string{"%s -json -json-indent london", "%s -also-json weather.json london", "%s -prometheus london"},
+	},
+	{
+		title: "Network",
+		flags: []string{
+			"timeout", "connect-timeout", "deadline", "retries", "retry-status", "retry-on-empty", "max-retries-total",
+			"ipv4", "header", "accept-language", "fallback", "no-stale", "dry-run",
+		},
+		examples: []string{"%s -timeout 5s -deadline 20s -retries 2 kyiv", "%s -fallback wttr london"},
+	},
+	{
+		title:    "Cities",
+		flags:    []string{"id", "coords", "fuzzy", "interactive", "cities-file", "repeat-header", "file"},
+		examples: []string{"%s -id 213", "%s -coords 55.75,37.62", "%s -cities-file offices.txt -json"},
+	},
+	{
+		title:    "Checks and alerts",
+		flags:    []string{"check", "check-selectors", "strict", "alert-below", "alert-above", "notify", "syslog"},
+		examples: []string{"%s -check moscow", "%s -alert-below -20 -no-color moscow || echo cold"},
+	},
+	{
+		title:    "Other",
+		flags:    []string{"verbose", "timing", "version", "help-all"},
+		examples: []string{"%s -verbose -timing london"},
+	},
+}
+
+//-----------------------------------------------------------------------------
+// print all options by groups with examples, in format of flag.PrintDefaults()
+func printHelpAll(writer io.Writer, flagSet *flag.FlagSet, program string) {
+	fmt.Fprintf(writer, "Usage: %s [options] [city]\n", program)
+
+	inGroups := map[string]bool{}
+	for i, group := range FlagGroups {
+		fmt.Fprintf(writer, "\n%s:\n", group.title)
+		for _, name := range group.flags {
+			inGroups[name] = true
+			if f := flagSet.Lookup(name); f != nil {
+				fmt.Fprint(writer, flagHelp(f))
+			}
+		}
+
+		// options which are not in any group
+		if i == len(FlagGroups)-1 {
+			other := []string{}
+			flagSet.VisitAll(func(f *flag.Flag) {
+				if !inGroups[f.Name] {
+					other = append(other, flagHelp(f))
+				}
+			})
+			sort.Strings(other)
+			fmt.Fprint(writer, strings.Join(other, ""))
+		}
+
+		if len(group.examples) > 0 {
+			fmt.Fprintln(writer, "  examples:")
+			for _, example := range group.examples {
+				fmt.Fprintln(writer, "    "+strings.Replace(example, "%s", program, -1))
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// get help of one option as in flag.PrintDefaults()
+func flagHelp(f *flag.Flag) string {
+	name, usage := flag.UnquoteUsage(f)
+	result := "  -" + f.Name
+	if name != "" {
+		result += " " + name
+	}
+	result += "\n    \t" + strings.Replace(usage, "\n", "\n    \t", -1)
+
+	switch f.DefValue {
+	case "", "0", "false", "0s":
+	default:
+		if name == "string" {
+			result += fmt.Sprintf(" (default %q)", f.DefValue)
+		} else {
+			result += fmt.Sprintf(" (default %v)", f.DefValue)
+		}
+	}
+
+	return result + "\n"
+}
@@ -0,0 +1,56 @@
+// desktop notifications via external commands
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// get command for desktop notification on os, empty name if os is not supported
+func getNotifyCommand(goos, title, message string) (string, []string) {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		return "osascript", []string{"-e", script}
+	case "windows":
+		script := "[reflection.assembly]::loadwithpartialname('System.Windows.Forms') | Out-Null;" +
+			"$n = New-Object System.Windows.Forms.NotifyIcon;" +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information;" +
+			"$n.Visible = $true;" +
+			fmt.Sprintf("$n.ShowBalloonTip(10000, %s, %s, 'None');", powerShellString(title), powerShellString(message)) +
+			"Start-Sleep -Seconds 1"
+		return "powershell", []string{"-NoProfile", "-Command", script}
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		// message starts with temperature, "-3 °C" is not an option
+		return "notify-send", []string{"--", title, message}
+	}
+	return "", nil
+}
+
+//-----------------------------------------------------------------------------
+// quote string for AppleScript
+func appleScriptString(str string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(str) + `"`
+}
+
+//-----------------------------------------------------------------------------
+// quote string for PowerShell
+func powerShellString(str string) string {
+	return "'" + strings.Replace(str, "'", "''", -1) + "'"
+}
+
+//-----------------------------------------------------------------------------
+// show desktop notification, error if there is no notifier
+func sendNotification(goos, title, message string) error {
+	name, args := getNotifyCommand(goos, title, message)
+	if name == "" {
+		return fmt.Errorf("notifications are not supported on %s", goos)
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("notifier %q not found", name)
+	}
+
+	return exec.Command(name, args...).Run() // #nosec
+}
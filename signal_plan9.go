@@ -0,0 +1,10 @@
+// +build plan9
+
+// shutdownSignals for plan9
+package main
+
+import (
+	"os"
+)
+
+var shutdownSignals = []os.Signal{os.Interrupt}
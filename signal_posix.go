@@ -0,0 +1,11 @@
+// +build !plan9
+
+// shutdownSignals for os-es with SIGTERM
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
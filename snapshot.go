@@ -0,0 +1,70 @@
+// snapshot of received pages and parsed weather for -snapshot, for new test fixtures
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pageSnapshot - pages received for -snapshot by requested URL, nil - without snapshot
+type pageSnapshot struct {
+	dir   string
+	mu    sync.Mutex
+	pages map[string][]byte
+}
+
+//-----------------------------------------------------------------------------
+// save page for snapshot, the last one for the same URL is saved (e.g. with -retry-on-empty)
+func (snapshot *pageSnapshot) add(pageURL string, body []byte) {
+	if snapshot == nil {
+		return
+	}
+
+	snapshot.mu.Lock()
+	defer snapshot.mu.Unlock()
+	if snapshot.pages == nil {
+		snapshot.pages = map[string][]byte{}
+	}
+	snapshot.pages[pageURL] = body
+}
+
+//-----------------------------------------------------------------------------
+// write pages (page.html, mini.html) and parsed weather (weather.json) to new directory
+// "<city>-YYYYMMDD-HHMMSS" in snapshot directory, returns path of it
+func saveSnapshot(snapshot *pageSnapshot, forecastNow map[string]interface{}, forecastByHours []HourTemp, forecastNext []DayForecast, cfg Config, now time.Time) (string, error) {
+	city := cfg.city
+	if city == "" {
+		city = "_default"
+	}
+	dir := filepath.Join(snapshot.dir, url.QueryEscape(city)+"-"+now.Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	snapshot.mu.Lock()
+	defer snapshot.mu.Unlock()
+	for fileName, pageURL := range map[string]string{"page.html": cfg.baseURL + cfg.city, "mini.html": cfg.baseURLMini + cfg.city} {
+		body, ok := snapshot.pages[pageURL]
+		if !ok {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, fileName), body, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(getForecastJSON(forecastNow, forecastByHours, forecastNext, cfg), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "weather.json"), append(jsonBytes, '\n'), 0644); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,13 @@
+// +build windows plan9
+
+// writeToSyslog() for os-es without syslog
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func writeToSyslog(_ string) error {
+	return fmt.Errorf("syslog is not supported on %s", runtime.GOOS)
+}
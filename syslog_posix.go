@@ -0,0 +1,18 @@
+// +build !windows,!plan9
+
+// writeToSyslog() for POSIX os-es
+package main
+
+import (
+	"log/syslog"
+)
+
+func writeToSyslog(message string) error {
+	logger, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "yandex-weather-cli")
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	return logger.Info(message)
+}
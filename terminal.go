@@ -7,15 +7,29 @@ import (
 
 type terminalWriter struct {
 	writer io.Writer
+	// -transliterate: format and values are converted before formatting,
+	// aligned cells of tables are converted before padding by renderers
+	transliterate bool
 }
 
 func (tw terminalWriter) Printf(format string, args ...interface{}) {
+	if tw.transliterate {
+		format = transliterateText(format)
+		for i, arg := range args {
+			if str, ok := arg.(string); ok {
+				args[i] = transliterateText(str)
+			}
+		}
+	}
 	if _, err := fmt.Fprintf(tw.writer, format, args...); err != nil {
 		fmt.Printf("failed to printf: %s", err)
 	}
 }
 
 func (tw terminalWriter) Print(s string) {
+	if tw.transliterate {
+		s = transliterateText(s)
+	}
 	if _, err := fmt.Fprint(tw.writer, s); err != nil {
 		fmt.Printf("failed to print: %s", err)
 	}
@@ -0,0 +1,13 @@
+// +build darwin dragonfly freebsd netbsd openbsd
+
+// ioctl requests for terminal settings on BSD os-es
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlReadTermios  = unix.TIOCGETA
+	ioctlWriteTermios = unix.TIOCSETA
+)
@@ -0,0 +1,13 @@
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+// rawTerminalInput() for other os-es
+package main
+
+import (
+	"errors"
+)
+
+// rawTerminalInput - raw terminal mode is not supported, -interactive asks city name by lines
+func rawTerminalInput(_ int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
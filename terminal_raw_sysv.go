@@ -0,0 +1,13 @@
+// +build aix linux solaris
+
+// ioctl requests for terminal settings on linux, aix and solaris
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlReadTermios  = unix.TCGETS
+	ioctlWriteTermios = unix.TCSETS
+)
@@ -0,0 +1,30 @@
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+// rawTerminalInput() for unix os-es
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// rawTerminalInput - switch terminal to read keys one by one without echo, for -interactive,
+// returns function for restore terminal
+func rawTerminalInput(fd int) (func(), error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+	saved := *termios
+
+	// Ctrl-C is read as key too, so terminal is restored on exit
+	termios.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, termios); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = unix.IoctlSetTermios(fd, ioctlWriteTermios, &saved)
+	}, nil
+}
@@ -0,0 +1,9 @@
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+// getTerminalWidth() for other os-es
+package main
+
+// getTerminalWidth - terminal width is unknown
+func getTerminalWidth() int {
+	return 0
+}
@@ -0,0 +1,19 @@
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+// getTerminalWidth() for unix os-es
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// getTerminalWidth - get width of terminal in columns, 0 if stdout is not a terminal
+func getTerminalWidth() int {
+	winSize, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(winSize.Col)
+}
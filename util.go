@@ -2,16 +2,114 @@
 package main
 
 import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/mgutz/ansi"
+	"golang.org/x/text/unicode/norm"
 )
 
 // HistoChars - chars for draw histogram
 var HistoChars = [...]string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
 
+// CelsiusSymbols - symbols for -celsius-symbol option: for headers and for values
+var CelsiusSymbols = map[string][2]string{
+	"°C":   {"°C", "°"},
+	"C":    {"C", "C"},
+	"none": {"", ""},
+}
+
+// TranslitRu - transliteration of russian letters to latin, like in Yandex city names
+var TranslitRu = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// KnownCitySlugs - Yandex slugs of cities with several words, which differ from transliteration
+var KnownCitySlugs = map[string]string{
+	"нижний новгород": "nizhny-novgorod",
+	"санкт-петербург": "saint-petersburg",
+	"санкт петербург": "saint-petersburg",
+	"ростов-на-дону":  "rostov-na-donu",
+	"ростов на дону":  "rostov-na-donu",
+}
+
+// WideRunes - runes with double width in terminal: emoji and east asian wide (W) and fullwidth (F) symbols
+var WideRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115F, Stride: 1},
+		{Lo: 0x231A, Hi: 0x231B, Stride: 1},
+		{Lo: 0x23E9, Hi: 0x23EC, Stride: 1},
+		{Lo: 0x23F0, Hi: 0x23F0, Stride: 1},
+		{Lo: 0x23F3, Hi: 0x23F3, Stride: 1},
+		{Lo: 0x25FD, Hi: 0x25FE, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x2648, Hi: 0x2653, Stride: 1},
+		{Lo: 0x267F, Hi: 0x267F, Stride: 1},
+		{Lo: 0x2693, Hi: 0x2693, Stride: 1},
+		{Lo: 0x26A1, Hi: 0x26A1, Stride: 1},
+		{Lo: 0x26AA, Hi: 0x26AB, Stride: 1},
+		{Lo: 0x26BD, Hi: 0x26BE, Stride: 1},
+		{Lo: 0x26C4, Hi: 0x26C5, Stride: 1},
+		{Lo: 0x26CE, Hi: 0x26CE, Stride: 1},
+		{Lo: 0x26D4, Hi: 0x26D4, Stride: 1},
+		{Lo: 0x26EA, Hi: 0x26EA, Stride: 1},
+		{Lo: 0x26F2, Hi: 0x26F3, Stride: 1},
+		{Lo: 0x26F5, Hi: 0x26F5, Stride: 1},
+		{Lo: 0x26FA, Hi: 0x26FA, Stride: 1},
+		{Lo: 0x26FD, Hi: 0x26FD, Stride: 1},
+		{Lo: 0x2705, Hi: 0x2705, Stride: 1},
+		{Lo: 0x270A, Hi: 0x270B, Stride: 1},
+		{Lo: 0x2728, Hi: 0x2728, Stride: 1},
+		{Lo: 0x274C, Hi: 0x274C, Stride: 1},
+		{Lo: 0x274E, Hi: 0x274E, Stride: 1},
+		{Lo: 0x2753, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2757, Hi: 0x2757, Stride: 1},
+		{Lo: 0x2795, Hi: 0x2797, Stride: 1},
+		{Lo: 0x27B0, Hi: 0x27B0, Stride: 1},
+		{Lo: 0x27BF, Hi: 0x27BF, Stride: 1},
+		{Lo: 0x2B1B, Hi: 0x2B1C, Stride: 1},
+		{Lo: 0x2B50, Hi: 0x2B50, Stride: 1},
+		{Lo: 0x2B55, Hi: 0x2B55, Stride: 1},
+		{Lo: 0x2E80, Hi: 0x303E, Stride: 1},
+		{Lo: 0x3041, Hi: 0x33FF, Stride: 1},
+		{Lo: 0x3400, Hi: 0x4DBF, Stride: 1},
+		{Lo: 0x4E00, Hi: 0x9FFF, Stride: 1},
+		{Lo: 0xA000, Hi: 0xA4CF, Stride: 1},
+		{Lo: 0xAC00, Hi: 0xD7A3, Stride: 1},
+		{Lo: 0xF900, Hi: 0xFAFF, Stride: 1},
+		{Lo: 0xFE30, Hi: 0xFE4F, Stride: 1},
+		{Lo: 0xFF00, Hi: 0xFF60, Stride: 1},
+		{Lo: 0xFFE0, Hi: 0xFFE6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F300, Hi: 0x1F64F, Stride: 1},
+		{Lo: 0x1F680, Hi: 0x1F6FF, Stride: 1},
+		{Lo: 0x1F900, Hi: 0x1F9FF, Stride: 1},
+		{Lo: 0x20000, Hi: 0x2FFFD, Stride: 1},
+		{Lo: 0x30000, Hi: 0x3FFFD, Stride: 1},
+	},
+}
+
+// TranslitSymbols - not ASCII symbols of output and their replacement for -transliterate
+var TranslitSymbols = map[rune]string{
+	'°': "", '−': "-", '─': "-", '…': "...", '↑': "^", '↓': "v", '→': ">",
+	'▁': "_", '▂': ".", '▃': "-", '▄': "=", '▅': "+", '▆': "*", '▇': "%", '█': "#",
+	'✻': "*", '☂': "'", '✓': "+", '✗': "x",
+}
+
 var weekdaysRu = [...]string{
 	"вс",
 	"пн",
@@ -22,6 +120,19 @@ var weekdaysRu = [...]string{
 	"сб",
 }
 
+var weekdaysRuLong = [...]string{
+	"воскресенье",
+	"понедельник",
+	"вторник",
+	"среда",
+	"четверг",
+	"пятница",
+	"суббота",
+}
+
+// WeekendRe - weekend days in human date for highlight, also transliterated ones
+var WeekendRe = regexp.MustCompile(`(сб|вс|суббота|воскресенье|sb|vs|subbota|voskresene)`)
+
 //-----------------------------------------------------------------------------
 // formatDates gets date in json and human format
 func formatDates(date time.Time) (formatDate string, jsonDate string) {
@@ -29,6 +140,32 @@ func formatDates(date time.Time) (formatDate string, jsonDate string) {
 		date.Format("2006-01-02")
 }
 
+//-----------------------------------------------------------------------------
+// get human date of day with -weekday-style: "02.01 (пн)" or "02.01 (понедельник)"
+func (cfg Config) formatDateHuman(day DayForecast) string {
+	if date, err := time.Parse("2006-01-02", day.Date); err == nil && cfg.weekdayStyle == "long" {
+		return date.Format("02.01") + " (" + weekdaysRuLong[date.Weekday()] + ")"
+	}
+	return day.DateHuman
+}
+
+//-----------------------------------------------------------------------------
+// get width of the longest human date with weekday style: "02.01 (ср)" or "02.01 (воскресенье)"
+func (cfg Config) maxDateWidth() int {
+	weekdays := weekdaysRu[:]
+	if cfg.weekdayStyle == "long" {
+		weekdays = weekdaysRuLong[:]
+	}
+
+	maxWidth := 0
+	for _, weekday := range weekdays {
+		if width := stringWidth(cfg.outputText("02.01 (" + weekday + ")")); width > maxWidth {
+			maxWidth = width
+		}
+	}
+	return maxWidth
+}
+
 //-----------------------------------------------------------------------------
 // safe convert string to int, return 0 on error
 func convertStrToInt(str string) int {
@@ -40,10 +177,47 @@ func convertStrToInt(str string) int {
 }
 
 //-----------------------------------------------------------------------------
-func getMaxLengthDesc(list []DayForecast) int {
+// safe convert first number in string to float ("3,5 м/с" -> 3.5), return 0 on error
+func convertStrToFloat(str string) float64 {
+	str = regexp.MustCompile(`[\x{2212}\x{2010}-\x{2013}\x{FE63}\x{FF0D}]`).ReplaceAllString(str, "-")
+	numberStr := regexp.MustCompile(`-?\d+([.,]\d+)?`).FindString(str)
+	number, err := strconv.ParseFloat(strings.Replace(numberStr, ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+//-----------------------------------------------------------------------------
+// get list of strings from map, also after JSON of cache ([]interface{}), empty list for missing value
+func stringsValue(data map[string]interface{}, key string) []string {
+	switch value := data[key].(type) {
+	case []string:
+		return value
+	case []interface{}:
+		result := []string{}
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	}
+	return []string{}
+}
+
+//-----------------------------------------------------------------------------
+// get string value from map, return empty string for missing or not string value
+func stringValue(data map[string]interface{}, key string) string {
+	value, _ := data[key].(string)
+	return value
+}
+
+//-----------------------------------------------------------------------------
+func getMaxLengthDesc(list []DayForecast, cfg Config) int {
 	maxLengh := 0
 	for _, row := range list {
-		length := len([]rune(row.Desc))
+		length := stringWidth(cfg.outputText(row.Desc))
 		if maxLengh < length {
 			maxLengh = length
 		}
@@ -52,11 +226,307 @@ func getMaxLengthDesc(list []DayForecast) int {
 	return maxLengh
 }
 
+//-----------------------------------------------------------------------------
+// parse city aliases: "alias = city" on each line, skip empty lines and "#" comments
+func parseAliases(content string) map[string]string {
+	result := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if alias, city := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]); alias != "" && city != "" {
+			result[alias] = city
+		}
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// dew point by temperature (°C) and relative humidity (%), Magnus formula
+func dewPoint(temp, humidity float64) float64 {
+	const a, b = 17.62, 243.12
+	gamma := math.Log(humidity/100) + a*temp/(b+temp)
+	return b * gamma / (a - gamma)
+}
+
+//-----------------------------------------------------------------------------
+// check that list has string
+func hasString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
+// check that list has number
+func hasInt(list []int, number int) bool {
+	for _, item := range list {
+		if item == number {
+			return true
+		}
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
+// get sparkline for values: one symbol for each value, from the lowest to the highest level
+func sparkline(values []int, ascii bool) string {
+	levels := HistoChars[:]
+	if ascii {
+		levels = strings.Split(SparklineLevelsASCII, "")
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	minValue, maxValue := values[0], values[0]
+	for _, value := range values {
+		if value < minValue {
+			minValue = value
+		}
+		if value > maxValue {
+			maxValue = value
+		}
+	}
+
+	result := ""
+	for _, value := range values {
+		level := (len(levels) - 1) / 2
+		if maxValue > minValue {
+			// rounded to the nearest level
+			level = ((value-minValue)*(len(levels)-1)*2 + (maxValue - minValue)) / ((maxValue - minValue) * 2)
+		}
+		result += levels[level]
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// truncate string to maxLength runes, with ellipsis at the end for truncated string
+func truncateString(str string, maxLength int) string {
+	if stringWidth(str) <= maxLength || maxLength < 1 {
+		return str
+	}
+
+	result, width := strings.Builder{}, 0
+	for _, char := range str {
+		if width+runeWidth(char) > maxLength-1 {
+			break
+		}
+		width += runeWidth(char)
+		result.WriteRune(char)
+	}
+	return result.String() + "…"
+}
+
+//-----------------------------------------------------------------------------
+// get width of rune in terminal: 2 for wide (emoji, CJK), 0 for combining marks, 1 for others
+func runeWidth(char rune) int {
+	switch {
+	case unicode.In(char, unicode.Mn, unicode.Me, unicode.Cf) || char == '\uFE0F':
+		return 0
+	case unicode.Is(WideRunes, char):
+		return 2
+	}
+	return 1
+}
+
+//-----------------------------------------------------------------------------
+// get width of string in terminal, wide runes are counted as two columns
+func stringWidth(str string) int {
+	width := 0
+	for _, char := range str {
+		width += runeWidth(char)
+	}
+	return width
+}
+
+//-----------------------------------------------------------------------------
+// pad string with spaces on the right up to width in terminal columns, as "%-*s" for wide runes
+func padRight(str string, width int) string {
+	if padding := width - stringWidth(str); padding > 0 {
+		return str + strings.Repeat(" ", padding)
+	}
+	return str
+}
+
+//-----------------------------------------------------------------------------
+// pad string with spaces on the left up to width in terminal columns, as "%*s" for wide runes
+func padLeft(str string, width int) string {
+	if padding := width - stringWidth(str); padding > 0 {
+		return strings.Repeat(" ", padding) + str
+	}
+	return str
+}
+
+//-----------------------------------------------------------------------------
+// normalize city name: trim spaces, compose unicode (NFC) and lower case, so "Киев" and "киев" is the same
+func normalizeCity(city string) string {
+	return strings.ToLower(norm.NFC.String(strings.TrimSpace(city)))
+}
+
+//-----------------------------------------------------------------------------
+// get city slug for URL: known slug for city with several words or words joined by "-" as in Yandex URLs,
+// "нижний новгород" -> "nizhny-novgorod", "новый  уренгой" -> "новый-уренгой"
+func normalizeCitySlug(city string) string {
+	if slug, ok := KnownCitySlugs[strings.Join(strings.Fields(city), " ")]; ok {
+		return slug
+	}
+	return strings.Join(strings.Fields(city), "-")
+}
+
+//-----------------------------------------------------------------------------
+// get city slug from pasted URL: "https://yandex.ru/pogoda/kiev/" -> "kiev", other names are kept as is
+func citySlug(city, baseURL string) string {
+	city = strings.TrimSpace(city)
+	if baseURL != "" && strings.HasPrefix(city, baseURL) {
+		city = strings.TrimPrefix(city, baseURL)
+	} else if reURL := regexp.MustCompile(`^(?i)(?:https?://)?(?:[\w-]+\.)+[a-z]+/(?:pogoda/)?`); reURL.MatchString(city) {
+		city = reURL.ReplaceAllString(city, "")
+	} else {
+		return city
+	}
+
+	if pos := strings.IndexAny(city, "?#"); pos >= 0 {
+		city = city[:pos]
+	}
+
+	return strings.Trim(city, "/")
+}
+
+//-----------------------------------------------------------------------------
+// transliterate russian letters to latin, other symbols are kept as is: "Йошкар-Ола" -> "yoshkar-ola"
+func transliterate(str string) string {
+	result := strings.Builder{}
+	for _, char := range strings.ToLower(str) {
+		if latin, ok := TranslitRu[char]; ok {
+			result.WriteString(latin)
+		} else {
+			result.WriteRune(char)
+		}
+	}
+
+	return result.String()
+}
+
+//-----------------------------------------------------------------------------
+// transliterate text for output with -transliterate: russian letters to latin with saved case,
+// other not ASCII symbols of output to ASCII: "Сейчас: −3 °C" -> "Seychas: -3 C"
+func transliterateText(str string) string {
+	result := strings.Builder{}
+	for _, char := range str {
+		if ascii, ok := TranslitSymbols[char]; ok {
+			result.WriteString(ascii)
+		} else if latin, ok := TranslitRu[unicode.ToLower(char)]; ok && unicode.IsUpper(char) {
+			result.WriteString(strings.Title(latin))
+		} else if ok {
+			result.WriteString(latin)
+		} else {
+			result.WriteRune(char)
+		}
+	}
+
+	return result.String()
+}
+
+//-----------------------------------------------------------------------------
+// get charset of locale from environment: "ru_RU.CP1251" -> "CP1251", empty if it is not set
+func localeCharset() string {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			charset := ""
+			if pos := strings.Index(locale, "."); pos >= 0 {
+				charset = locale[pos+1:]
+			}
+			if pos := strings.Index(charset, "@"); pos >= 0 {
+				charset = charset[:pos]
+			}
+			return charset
+		}
+	}
+
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// check that city is a Yandex numeric ID (like "213") instead of a name
+func isCityID(city string) bool {
+	return regexp.MustCompile(`^\d+$`).MatchString(city)
+}
+
+//-----------------------------------------------------------------------------
+// get query of weather page by coordinates: "55.75,37.62" -> "?lat=55.75&lon=37.62", false if format is wrong
+func coordsQuery(coords string) (string, bool) {
+	parts := strings.Split(coords, ",")
+	if len(parts) != 2 {
+		return "", false
+	}
+
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, errLon := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLat != nil || errLon != nil || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return "", false
+	}
+
+	return "?lat=" + strconv.FormatFloat(lat, 'f', -1, 64) + "&lon=" + strconv.FormatFloat(lon, 'f', -1, 64), true
+}
+
+//-----------------------------------------------------------------------------
+// parse list of HTTP status codes for -retry-status: "429,503", returns wrong value if any
+func parseRetryStatus(list string) ([]int, string) {
+	codes := []int{}
+	for _, code := range strings.Split(list, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		number, err := strconv.Atoi(code)
+		if err != nil || number < 100 || number > 599 {
+			return nil, code
+		}
+		codes = append(codes, number)
+	}
+
+	return codes, ""
+}
+
+//-----------------------------------------------------------------------------
+// get delay before retry from Retry-After header: seconds or HTTP date,
+// default delay if header is empty or wrong, not more than RetryAfterMax
+func retryAfterDelay(retryAfter string, now time.Time, defaultDelay time.Duration) time.Duration {
+	retryAfter = strings.TrimSpace(retryAfter)
+	delay := defaultDelay
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(retryAfter); err == nil {
+		if delay = date.Sub(now); delay < 0 {
+			delay = 0
+		}
+	}
+
+	if delay > RetryAfterMax {
+		delay = RetryAfterMax
+	}
+	return delay
+}
+
 //-----------------------------------------------------------------------------
 // clear all non numeric symbols in string
 func clearIntegerInString(in string) (out string) {
-	// replace dashes to minus
-	out = regexp.MustCompile(string([]byte{0xE2, 0x88, 0x92})).ReplaceAllString(in, "-")
+	// replace unicode minus (−), hyphen, figure dash, en dash, small and fullwidth hyphen-minus to minus
+	out = regexp.MustCompile(`[\x{2212}\x{2010}-\x{2013}\x{FE63}\x{FF0D}]`).ReplaceAllString(in, "-")
 
 	// clear non numeric symbols
 	out = regexp.MustCompile(`[^\d-]+`).ReplaceAllString(out, "")
@@ -65,17 +535,102 @@ func clearIntegerInString(in string) (out string) {
 }
 
 //-----------------------------------------------------------------------------
-// clear all non print symbols in string
+// clear all non print symbols in string: unicode spaces (thin space, no-break space, ...)
+// replaces to regular space, invisible symbols (zero width space, ...) removes, trim spaces
 func clearNonprintInString(in string) (out string) {
-	// replace spaces
-	out = regexp.MustCompile(string([]byte{0xE2, 0x80, 0x89})).ReplaceAllString(in, " ")
+	out = strings.Map(func(r rune) rune {
+		switch {
+		case r > unicode.MaxASCII && unicode.IsSpace(r):
+			return ' '
+		case unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		return r
+	}, in)
 
-	return out
+	return strings.TrimSpace(out)
 }
 
 //-----------------------------------------------------------------------------
-// convert "<red>123</> str <green>456</green>" to ansi color string
+// tempUnit gets temperature unit for headers
+func (cfg Config) tempUnit() string {
+	return CelsiusSymbols[cfg.celsiusSymbol][0]
+}
+
+//-----------------------------------------------------------------------------
+// formatTemp gets temperature with degree symbol for table values
+func (cfg Config) formatTemp(temp int) string {
+	return cfg.formatNumber(temp) + CelsiusSymbols[cfg.celsiusSymbol][1]
+}
+
+//-----------------------------------------------------------------------------
+// get text for output: converted to latin with -transliterate, as is without it
+func (cfg Config) outputText(str string) string {
+	if cfg.transliterate {
+		return transliterateText(str)
+	}
+	return str
+}
+
+//-----------------------------------------------------------------------------
+// format temperature which can be absent, empty string if it is nil
+func (cfg Config) formatTempPointer(temp *int) string {
+	if temp == nil {
+		return ""
+	}
+	return cfg.formatTemp(*temp)
+}
+
+//-----------------------------------------------------------------------------
+// formatTempWithUnit gets temperature with unit: "-3 °C"
+func (cfg Config) formatTempWithUnit(temp int) string {
+	return strings.TrimSpace(cfg.formatNumber(temp) + " " + cfg.tempUnit())
+}
+
+//-----------------------------------------------------------------------------
+// print time from start of phase to stderr with -timing or -verbose
+func (cfg Config) logTiming(phase string, start time.Time) {
+	if cfg.timing || cfg.verbose {
+		fmt.Fprintf(os.Stderr, "timing: %s: %d ms\n", phase, time.Since(start).Milliseconds())
+	}
+}
+
+//-----------------------------------------------------------------------------
+// print message to stderr with -verbose
+func (cfg Config) logVerbose(format string, args ...interface{}) {
+	if cfg.verbose {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// formatNumber gets number with ASCII or unicode minus sign
+func (cfg Config) formatNumber(number int) string {
+	if number < 0 && cfg.unicodeMinus {
+		return "−" + strconv.Itoa(-number)
+	}
+	return strconv.Itoa(number)
+}
+
+//-----------------------------------------------------------------------------
+// convert "<red>123</> str <green>456</green>" to ansi color string,
+// semantic tags like "<value>" converts to colors from current theme
 func (cfg Config) ansiColourString(str string) string {
+	theme, ok := Themes[cfg.theme]
+	if !ok {
+		theme = Themes[ThemeDefault]
+	}
+	str = regexp.MustCompile(`<[a-z]+>`).ReplaceAllStringFunc(str, func(in string) string {
+		color, ok := theme[in[1:len(in)-1]]
+		switch {
+		case !ok:
+			return in
+		case color == "":
+			return ""
+		}
+		return "<" + color + ">"
+	})
+
 	oneColor := `(black|red|green|yellow|blue|magenta|cyan|white|grey|\d{1,3})(\+[bBuih]+)?`
 	re := regexp.MustCompile(`<(` + oneColor + `(:` + oneColor + `)?|/\w*)>`)
 	result := re.ReplaceAllStringFunc(str, func(in string) (out string) {
@@ -95,6 +650,66 @@ func (cfg Config) ansiColourString(str string) string {
 	return result
 }
 
+//-----------------------------------------------------------------------------
+// colorize description by condition, with color of defaultRole for unknown one
+func (cfg Config) colorDesc(desc, defaultRole string) string {
+	role, ok := ConditionColorRoles[descCondition(desc)]
+	if !ok {
+		role = defaultRole
+	}
+	if role == "" {
+		return desc
+	}
+	return cfg.ansiColourString("<" + role + ">" + desc + "</>")
+}
+
+//-----------------------------------------------------------------------------
+// parse -template, with function for colors: {{color "red" .TermNow}} or {{color "value" .TermNow}}
+func (cfg Config) parseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(template.FuncMap{
+		"color": func(color string, value interface{}) string {
+			return cfg.ansiColourString("<" + color + ">" + fmt.Sprint(value) + "</>")
+		},
+	}).Parse(text)
+}
+
+//-----------------------------------------------------------------------------
+// print sample of each semantic color of current theme with its ansi code, for -color-test
+func renderColorTest(outWriter terminalWriter, cfg Config) {
+	theme, ok := Themes[cfg.theme]
+	if !ok {
+		theme = Themes[ThemeDefault]
+	}
+	roles := []string{}
+	for role := range theme {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	outWriter.Printf("theme: %s, colors: %s\n", cfg.theme, map[bool]string{false: "on", true: "off"}[cfg.noColor])
+	for _, role := range roles {
+		color, code := theme[role], ""
+		if color == "" {
+			color = "-"
+		} else if !cfg.noColor {
+			code = ansi.ColorCode(color)
+		}
+		outWriter.Printf("%-8s %s %-8s %q\n", role, cfg.ansiColourString("<"+role+">sample</>"), color, code)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// get sorted names of color themes
+func getThemeNames() []string {
+	result := []string{}
+	for name := range Themes {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
 // ----------------------------------------------------------------------------
 // Render histogram for forecast by hours
 func renderHisto(forecastByHours []HourTemp) string {
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"math"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mgutz/ansi"
+	"github.com/msoap/html2data"
 )
 
 func Test_clearNonprintInString(t *testing.T) {
@@ -13,7 +20,14 @@ func Test_clearNonprintInString(t *testing.T) {
 		wantOut string
 	}{
 		{"simple string", "str", "str"},
-		{"string with unprinted", string([]byte{0xE2, 0x80, 0x89}) + "str", " str"},
+		{"string with unprinted", string([]byte{0xE2, 0x80, 0x89}) + "str", "str"},
+		{"thin space", "745\u2009мм", "745 мм"},
+		{"no-break space", "3\u00a0м/с", "3 м/с"},
+		{"narrow no-break space", "75\u202f%", "75 %"},
+		{"zero width space", "ясно\u200b", "ясно"},
+		{"zero width space inside", "об\u200bлачно", "облачно"},
+		{"spaces around", "\u00a0 −3\u2009", "−3"},
+		{"newline saved", "Москва\nпогода", "Москва\nпогода"},
 	}
 
 	for _, tt := range tests {
@@ -23,9 +37,273 @@ func Test_clearNonprintInString(t *testing.T) {
 	}
 }
 
+func Test_formatDateHuman(t *testing.T) {
+	tests := []struct {
+		day          DayForecast
+		weekdayStyle string
+		want         string
+	}{
+		{DayForecast{DateHuman: "29.06 (вт)", Date: "2021-06-29"}, "short", "29.06 (вт)"},
+		{DayForecast{DateHuman: "29.06 (вт)", Date: "2021-06-29"}, "long", "29.06 (вторник)"},
+		{DayForecast{DateHuman: "04.07 (вс)", Date: "2021-07-04"}, "long", "04.07 (воскресенье)"},
+		{DayForecast{DateHuman: "04.07 (вс)", Date: ""}, "long", "04.07 (вс)"},
+	}
+
+	for _, tt := range tests {
+		if got := (Config{weekdayStyle: tt.weekdayStyle}).formatDateHuman(tt.day); got != tt.want {
+			t.Errorf("formatDateHuman(%q, %s): expected: %#v, real: %#v", tt.day.Date, tt.weekdayStyle, tt.want, got)
+		}
+	}
+}
+
+func Test_dewPoint(t *testing.T) {
+	tests := []struct {
+		temp     float64
+		humidity float64
+		want     float64
+	}{
+		{20, 50, 9.3},
+		{25, 60, 16.7},
+		{30, 80, 26.2},
+		{0, 100, 0},
+		{-5, 80, -7.9},
+	}
+
+	for _, tt := range tests {
+		if got := dewPoint(tt.temp, tt.humidity); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("dewPoint(%v, %v) = %v, want %v", tt.temp, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+func Test_normalizeCity(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"moscow", "moscow"},
+		{" London\t", "london"},
+		{"Киев", "киев"},
+		{"КИЕВ", "киев"},
+		// "й" composed and decomposed to "и" + combining breve
+		{"Йошкар-Ола", "йошкар-ола"},
+		{"И\u0306ошкар-Ола", "йошкар-ола"},
+		{"\u0439", "\u0439"},
+		{"\u0438\u0306", "\u0439"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeCity(tt.in); got != tt.want {
+			t.Errorf("normalizeCity(%q): expected: %#v, real: %#v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func Test_citySlug(t *testing.T) {
+	tests := []struct {
+		in      string
+		baseURL string
+		want    string
+	}{
+		{"kiev", BaseURLDefault, "kiev"},
+		{"Нижний Новгород", BaseURLDefault, "Нижний Новгород"},
+		{"https://yandex.ru/pogoda/kiev", BaseURLDefault, "kiev"},
+		{" https://yandex.ru/pogoda/kiev/ ", BaseURLDefault, "kiev"},
+		{"https://pogoda.yandex.ru/kiev", BaseURLDefault, "kiev"},
+		{"http://yandex.ru/pogoda/moscow?lat=55.75&lon=37.62", BaseURLDefault, "moscow"},
+		{"yandex.ru/pogoda/213#today", BaseURLDefault, "213"},
+		{"HTTPS://Yandex.Ru/pogoda/london//", BaseURLDefault, "london"},
+		{"http://localhost:8080/get?url=https://yandex.ru/pogoda/omsk", "http://localhost:8080/get?url=https://yandex.ru/pogoda/", "omsk"},
+	}
+
+	for _, tt := range tests {
+		if got := citySlug(tt.in, tt.baseURL); got != tt.want {
+			t.Errorf("citySlug(%q): expected: %#v, real: %#v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func Test_normalizeCitySlug(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"moscow", "moscow"},
+		{"213", "213"},
+		{"нижний новгород", "nizhny-novgorod"},
+		{"нижний  новгород", "nizhny-novgorod"},
+		{"санкт-петербург", "saint-petersburg"},
+		{"ростов на дону", "rostov-na-donu"},
+		{"новый уренгой", "новый-уренгой"},
+		{"new york", "new-york"},
+		{"nizhny-novgorod", "nizhny-novgorod"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeCitySlug(tt.in); got != tt.want {
+			t.Errorf("normalizeCitySlug(%q): expected: %#v, real: %#v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func Test_sparkline(t *testing.T) {
+	tests := []struct {
+		values []int
+		ascii  bool
+		want   string
+	}{
+		{nil, false, ""},
+		{[]int{-7, 0, 7}, false, "▁▅█"},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8}, false, "▁▂▃▄▅▆▇█"},
+		{[]int{3, 3, 3}, false, "▄▄▄"},
+		{[]int{-2, 2, 4, 1}, true, "_*#+"},
+	}
+
+	for _, tt := range tests {
+		if got := sparkline(tt.values, tt.ascii); got != tt.want {
+			t.Errorf("sparkline(%v, %v): expected: %#v, real: %#v", tt.values, tt.ascii, tt.want, got)
+		}
+	}
+}
+
+func Test_transliterate(t *testing.T) {
+	tests := map[string]string{
+		"Москва":          "moskva",
+		"Йошкар-Ола":      "yoshkar-ola",
+		"щёлково":         "shchyolkovo",
+		"нижний-новгород": "nizhniy-novgorod",
+		"Ханты-Мансийск":  "khanty-mansiysk",
+		"sochi":           "sochi",
+		"объячево":        "obyachevo",
+	}
+
+	for in, want := range tests {
+		if got := transliterate(in); got != want {
+			t.Errorf("transliterate(%q): expected: %#v, real: %#v", in, want, got)
+		}
+	}
+}
+
+func Test_transliterateText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Погода в Москве (https://yandex.ru/pogoda/)", "Pogoda v Moskve (https://yandex.ru/pogoda/)"},
+		{"Сейчас: −3 °C - Облачно", "Seychas: -3 C - Oblachno"},
+		{"Щёлково, ЮГ", "Shchyolkovo, YuG"},
+		{"Давление: 745 мм рт. ст. ↑", "Davlenie: 745 mm rt. st. ^"},
+		{"▁▄█", "_=#"},
+		{"London", "London"},
+	}
+
+	for _, tt := range tests {
+		if got := transliterateText(tt.in); got != tt.want {
+			t.Errorf("transliterateText(%q): expected: %#v, real: %#v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func Test_localeCharset(t *testing.T) {
+	names := []string{"LC_ALL", "LC_CTYPE", "LANG"}
+	for _, name := range names {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"LANG": "ru_RU.UTF-8"}, "UTF-8"},
+		{map[string]string{"LANG": "ru_RU.CP1251"}, "CP1251"},
+		{map[string]string{"LANG": "ru_RU.UTF-8", "LC_ALL": "ru_RU.KOI8-R"}, "KOI8-R"},
+		{map[string]string{"LC_CTYPE": "sr_RS.UTF-8@latin"}, "UTF-8"},
+		{map[string]string{"LANG": "C"}, ""},
+	}
+
+	for _, tt := range tests {
+		for _, name := range names {
+			os.Setenv(name, tt.env[name])
+		}
+		if got := localeCharset(); got != tt.want {
+			t.Errorf("localeCharset(%v): expected: %#v, real: %#v", tt.env, tt.want, got)
+		}
+	}
+}
+
+func Test_isCityID(t *testing.T) {
+	tests := map[string]bool{
+		"213":    true,
+		"0":      true,
+		"moscow": false,
+		"213a":   false,
+		"-213":   false,
+		"":       false,
+	}
+
+	for city, want := range tests {
+		if got := isCityID(city); got != want {
+			t.Errorf("isCityID(%q): expected: %#v, real: %#v", city, want, got)
+		}
+	}
+}
+
+func Test_truncateString(t *testing.T) {
+	tests := []struct {
+		in        string
+		maxLength int
+		want      string
+	}{
+		{"ясно", 10, "ясно"},
+		{"ясно", 4, "ясно"},
+		{"небольшой снег", 8, "небольш…"},
+		{"cloudy", 1, "…"},
+		{"cloudy", 0, "cloudy"},
+		{"⛅ облачно", 5, "⛅ о…"},
+		{"雪雪雪", 4, "雪…"},
+	}
+
+	for _, tt := range tests {
+		if got := truncateString(tt.in, tt.maxLength); got != tt.want {
+			t.Errorf("%q. truncateString() = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func Test_stringWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"ясно", 4},
+		{"✻ снег", 6},
+		{"⛅ облачно", 10},
+		{"🌧", 2},
+		{"☀️", 1},
+		{"雪", 2},
+		{"и\u0306", 1},
+	}
+
+	for _, tt := range tests {
+		if got := stringWidth(tt.in); got != tt.want {
+			t.Errorf("stringWidth(%q): expected: %#v, real: %#v", tt.in, tt.want, got)
+		}
+		if got := padRight(tt.in, 6); stringWidth(got) != 6 && tt.want <= 6 {
+			t.Errorf("padRight(%q, 6): expected width 6, real: %q", tt.in, got)
+		}
+		if got := padLeft(tt.in, 6); stringWidth(got) != 6 && tt.want <= 6 {
+			t.Errorf("padLeft(%q, 6): expected width 6, real: %q", tt.in, got)
+		}
+	}
+}
+
 func Test_ansiColourString(t *testing.T) {
 	tests := []struct {
 		name    string
+		theme   string
 		city    string
 		getJSON bool
 		noColor bool
@@ -69,10 +347,37 @@ func Test_ansiColourString(t *testing.T) {
 			str:     "string <green>green",
 			want:    "string " + ansi.ColorCode("green") + "green",
 		},
+		{
+			name:    "with theme role",
+			noColor: false,
+			str:     "string <value>green</>",
+			want:    "string " + ansi.ColorCode("green") + "green" + ansi.ColorCode("reset"),
+		},
+		{
+			name:    "with light theme role",
+			theme:   "light",
+			noColor: false,
+			str:     "<header>header</>",
+			want:    ansi.ColorCode("18+b") + "header" + ansi.ColorCode("reset"),
+		},
+		{
+			name:    "with mono theme role",
+			theme:   "mono",
+			noColor: false,
+			str:     "string <value>value</>",
+			want:    "string value" + ansi.ColorCode("reset"),
+		},
+		{
+			name:    "with noColor, with theme role",
+			noColor: true,
+			str:     "string <value>value</>",
+			want:    "string value",
+		},
 	}
 
 	for _, tt := range tests {
 		cfg := Config{
+			theme:   tt.theme,
 			city:    tt.city,
 			getJSON: tt.getJSON,
 			noColor: tt.noColor,
@@ -84,6 +389,87 @@ func Test_ansiColourString(t *testing.T) {
 	}
 }
 
+func Test_parseTemplate(t *testing.T) {
+	forecast := ForecastJSON{City: "Москва", CurrentWeather: &CurrentWeather{TermNow: -3}, NextDays: []DayForecast{{Temp: 2}, {Temp: 4}}}
+	testData := []struct {
+		cfg  Config
+		text string
+		out  string
+	}{
+		{Config{}, "{{.City}}: {{.TermNow}}{{range .NextDays}} {{.Temp}}{{end}}", "Москва: -3 2 4"},
+		{Config{}, `{{color "red" .TermNow}}`, ansi.ColorCode("red") + "-3" + ansi.ColorCode("reset")},
+		{Config{theme: "dark"}, `{{color "value" .City}}`, ansi.ColorCode("green") + "Москва" + ansi.ColorCode("reset")},
+		{Config{noColor: true}, `{{color "red" .TermNow}}`, "-3"},
+	}
+
+	for i, item := range testData {
+		tmpl, err := item.cfg.parseTemplate(item.text)
+		out := strings.Builder{}
+		if err == nil {
+			err = tmpl.Execute(&out, forecast)
+		}
+		if err != nil || out.String() != item.out {
+			t.Errorf("%d. expected: %#v, real: %#v (%v)", i, item.out, out.String(), err)
+		}
+	}
+
+	if _, err := (Config{}).parseTemplate("{{.City}"); err == nil {
+		t.Errorf("expected error for invalid template")
+	}
+}
+
+func Test_renderColorTest(t *testing.T) {
+	out := bytes.Buffer{}
+	renderColorTest(terminalWriter{writer: &out}, Config{theme: "dark", noColor: true})
+	if !strings.HasPrefix(out.String(), "theme: dark, colors: off\n") || strings.Contains(out.String(), "\x1b") ||
+		!strings.Contains(out.String(), "value    sample green    \"\"\n") {
+		t.Errorf("expected colors of theme without ansi codes, real: %q", out.String())
+	}
+
+	out.Reset()
+	renderColorTest(terminalWriter{writer: &out}, Config{theme: "dark"})
+	expected := "value    " + ansi.ColorCode("green") + "sample" + ansi.ColorCode("reset") + " green    " + `"\x1b[0;32m"` + "\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("expected: %q in output, real: %q", expected, out.String())
+	}
+
+	out.Reset()
+	renderColorTest(terminalWriter{writer: &out}, Config{theme: "mono"})
+	if !strings.Contains(out.String(), "value    sample"+ansi.ColorCode("reset")+" -        \"\"\n") {
+		t.Errorf("expected theme without colors, real: %q", out.String())
+	}
+}
+
+func Test_formatTemp(t *testing.T) {
+	tests := []struct {
+		celsiusSymbol    string
+		unicodeMinus     bool
+		temp             int
+		wantUnit         string
+		wantTemp         string
+		wantTempWithUnit string
+	}{
+		{"°C", false, -3, "°C", "-3°", "-3 °C"},
+		{"°C", true, -3, "°C", "−3°", "−3 °C"},
+		{"C", true, 5, "C", "5C", "5 C"},
+		{"none", false, 0, "", "0", "0"},
+		{"none", false, -10, "", "-10", "-10"},
+	}
+
+	for _, tt := range tests {
+		cfg := Config{celsiusSymbol: tt.celsiusSymbol, unicodeMinus: tt.unicodeMinus}
+		if got := cfg.tempUnit(); got != tt.wantUnit {
+			t.Errorf("%q. Config.tempUnit() = %v, want %v", tt.celsiusSymbol, got, tt.wantUnit)
+		}
+		if got := cfg.formatTemp(tt.temp); got != tt.wantTemp {
+			t.Errorf("%q. Config.formatTemp() = %v, want %v", tt.celsiusSymbol, got, tt.wantTemp)
+		}
+		if got := cfg.formatTempWithUnit(tt.temp); got != tt.wantTempWithUnit {
+			t.Errorf("%q. Config.formatTempWithUnit() = %v, want %v", tt.celsiusSymbol, got, tt.wantTempWithUnit)
+		}
+	}
+}
+
 func Test_renderHisto(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -217,6 +603,114 @@ func Test_renderHisto(t *testing.T) {
 	}
 }
 
+func Test_getMissingSelectors(t *testing.T) {
+	html := `<html><head><title>Погода в Москве</title></head><body>
+		<div class="fact">
+			<div class="fact__temp">−3</div>
+			<div class="link__condition">Облачно</div>
+			<div class="fact__props"><div class="fact__humidity">Влажность: 75%</div></div>
+		</div>
+		<div class="forecast-briefly__days">
+			<time class="time" datetime="2021-06-29">29</time>
+			<div class="forecast-briefly__condition">Облачно</div>
+			<div class="forecast-briefly__temp_day"><span class="temp__value">+24</span></div>
+		</div>
+	</body></html>`
+
+	missing, err := getMissingSelectors(html2data.FromReader(strings.NewReader(html)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"pressure", "wind", "temp_night"}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("getMissingSelectors() = %v, want %v", missing, expected)
+	}
+
+	stats, err := getSelectorsStat(html2data.FromReader(strings.NewReader(html)), SelectorsNextDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []selectorStat{
+		{name: "date", count: 1, sample: "2021-06-29"},
+		{name: "desc", count: 1, sample: "Облачно"},
+		{name: "temp", count: 1, sample: "+24"},
+		{name: "temp_feels", count: 0, sample: ""},
+		{name: "temp_night", count: 0, sample: ""},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("getSelectorsStat() = %v, want %v", stats, expected)
+	}
+}
+
 func Test_getColorWriter(t *testing.T) {
 	getColorWriter(true)
 }
+
+func Test_coordsQuery(t *testing.T) {
+	testData := []struct {
+		in  string
+		out string
+		ok  bool
+	}{
+		{"55.75,37.62", "?lat=55.75&lon=37.62", true},
+		{" 55.750, 37.6200 ", "?lat=55.75&lon=37.62", true},
+		{"-33.87,151.21", "?lat=-33.87&lon=151.21", true},
+		{"90,-180", "?lat=90&lon=-180", true},
+		{"91,37.62", "", false},
+		{"55.75,181", "", false},
+		{"55.75", "", false},
+		{"55.75,37.62,1", "", false},
+		{"55,75,37,62", "", false},
+		{"lat,lon", "", false},
+		{"", "", false},
+	}
+
+	for _, item := range testData {
+		if out, ok := coordsQuery(item.in); out != item.out || ok != item.ok {
+			t.Errorf("%q: expected: %#v, %v, real: %#v, %v", item.in, item.out, item.ok, out, ok)
+		}
+	}
+}
+
+func Test_parseRetryStatus(t *testing.T) {
+	testData := []struct {
+		in    string
+		out   []int
+		wrong string
+	}{
+		{"429,503", []int{429, 503}, ""},
+		{" 429, 503 ,", []int{429, 503}, ""},
+		{"", []int{}, ""},
+		{"429,abc", nil, "abc"},
+		{"42", nil, "42"},
+		{"600", nil, "600"},
+	}
+
+	for _, item := range testData {
+		if out, wrong := parseRetryStatus(item.in); !reflect.DeepEqual(out, item.out) || wrong != item.wrong {
+			t.Errorf("%q: expected: %#v, %q, real: %#v, %q", item.in, item.out, item.wrong, out, wrong)
+		}
+	}
+}
+
+func Test_retryAfterDelay(t *testing.T) {
+	now := time.Date(2030, 1, 2, 12, 0, 0, 0, time.UTC)
+	testData := []struct {
+		in  string
+		out time.Duration
+	}{
+		{"", time.Second},
+		{"5", 5 * time.Second},
+		{" 0 ", 0},
+		{"3600", RetryAfterMax},
+		{"-1", time.Second},
+		{"soon", time.Second},
+		{"Wed, 02 Jan 2030 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 02 Jan 2030 11:00:00 GMT", 0},
+	}
+
+	for _, item := range testData {
+		if out := retryAfterDelay(item.in, now, time.Second); out != item.out {
+			t.Errorf("%q: expected: %s, real: %s", item.in, item.out, out)
+		}
+	}
+}
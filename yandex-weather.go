@@ -18,28 +18,205 @@ https://github.com/msoap/yandex-weather-cli
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/msoap/html2data"
+	"golang.org/x/net/html/charset"
 )
 
 // Config - application config
 type Config struct {
-	baseURL     string
-	baseURLMini string
-	city        string
-	getJSON     bool
-	noColor     bool
-	noToday     bool
-	daysLimit   int
+	baseURL        string
+	baseURLMini    string
+	city           string
+	getJSON        bool
+	noColor        bool
+	noToday        bool
+	daysLimit      int
+	celsiusSymbol  string
+	toSyslog       bool
+	prometheus     bool
+	noHeader       bool
+	comfort        bool
+	timeout        time.Duration
+	deadline       time.Duration
+	noCurrent      bool
+	theme          string
+	skipDays       int
+	maxWidth       int
+	noStale        bool
+	swapDayNight   bool
+	alsoJSON       string
+	ipv4           bool
+	unicodeMinus   bool
+	check          bool
+	checkSelectors bool
+	forecastFormat string
+	dewPoint       bool
+	cityID         string
+	coords         string
+	jsonIndent     bool
+	citiesFile     string
+	htmlFile       string
+	appendJSON     bool
+	summary        bool
+	jsonArray      bool
+	verbose        bool
+	columns        []string
+	retries        int
+	feels          bool
+	noLink         bool
+	precision      bool
+	repeatHeader   bool
+	timing         bool
+	alertBelow     *int
+	alertAbove     *int
+	notify         bool
+	fuzzy          bool
+	separator      string
+	sparkline      bool
+	ascii          bool
+	fallback       string
+	compactJSON    bool
+	weekdayStyle   string
+	transliterate  bool
+	average        bool
+	headers        headerFlags
+	acceptLanguage string
+	brief          bool
+	cityName       string
+	minWidth       int
+	connectTimeout time.Duration
+	meta           bool
+	retryOnEmpty   int
+	noPressure     bool
+	noHumidity     bool
+	noWind         bool
+	tempsOnly      bool
+	colorTest      bool
+	outputTemplate *template.Template
+	retryBudget    *retryBudget
+	strict         bool
+	jsonFlat       bool
+	dryRun         bool
+	weekends       bool
+	interactive    bool
+	pressureTrend  bool
+	retryStatus    []int
+	colorWhenPiped bool
+	snapshot       *pageSnapshot
+	// days are not filtered by -skip and -weekends on parsing, they are filtered by filterDays() after saving to cache
+	allDays bool
+}
+
+// headerFlags - HTTP headers from repeatable -header option
+type headerFlags http.Header
+
+//-----------------------------------------------------------------------------
+// String - value of -header option for flag package
+func (headers headerFlags) String() string {
+	result := []string{}
+	for key, values := range headers {
+		for _, value := range values {
+			result = append(result, key+": "+value)
+		}
+	}
+	sort.Strings(result)
+	return strings.Join(result, ", ")
+}
+
+//-----------------------------------------------------------------------------
+// Set - add header from "Key: Value" for flag package
+func (headers headerFlags) Set(header string) error {
+	parts := strings.SplitN(header, ":", 2)
+	if len(parts) != 2 || !regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$").MatchString(strings.TrimSpace(parts[0])) {
+		return fmt.Errorf("header %q must be in format \"Key: Value\"", header)
+	}
+	http.Header(headers).Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	return nil
+}
+
+// CurrentWeather - current weather for JSON output
+type CurrentWeather struct {
+	TermNow         int      `json:"term_now"`
+	DescNow         string   `json:"desc_now,omitempty"`
+	Pressure        string   `json:"pressure,omitempty"`
+	PressureTrend   string   `json:"pressure_trend,omitempty"`
+	PressureValue   *float64 `json:"pressure_value,omitempty"`
+	Humidity        string   `json:"humidity,omitempty"`
+	HumidityValue   *float64 `json:"humidity_value,omitempty"`
+	HumidityComfort string   `json:"humidity_comfort,omitempty"`
+	Wind            string   `json:"wind,omitempty"`
+	WindGust        string   `json:"wind_gust,omitempty"`
+	DewPoint        *float64 `json:"dew_point,omitempty"`
+	LocalTime       string   `json:"local_time,omitempty"`
+	UTCOffset       string   `json:"utc_offset,omitempty"`
+	ObservedAt      string   `json:"observed_at,omitempty"`
+	ObservedTime    string   `json:"observed_time,omitempty"`
+}
+
+// ForecastJSON - forecast for JSON output
+type ForecastJSON struct {
+	City      string `json:"city"`
+	SourceURL string `json:"source_url"`
+	*CurrentWeather
+	TempUnit    string        `json:"temp_unit"`
+	ByHours     []HourTemp    `json:"by_hours,omitempty"`
+	NextDays    []DayForecast `json:"next_days,omitempty"`
+	ForecastMin *int          `json:"forecast_min,omitempty"`
+	ForecastMax *int          `json:"forecast_max,omitempty"`
+	ForecastAvg *int          `json:"forecast_avg,omitempty"`
+	Meta        *MetaJSON     `json:"_meta,omitempty"`
+}
+
+// flatForecastJSON - forecast for JSON output with -json-flat, with keys of each day instead of next_days
+type flatForecastJSON ForecastJSON
+
+// MetaJSON - found and empty selectors for JSON output with -meta
+type MetaJSON struct {
+	Found []string `json:"found"`
+	Empty []string `json:"empty"`
+}
+
+// ErrorJSON - error for JSON output
+type ErrorJSON struct {
+	Error string `json:"error"`
+	City  string `json:"city,omitempty"`
+}
+
+// BriefJSON - brief weather for JSON output with -brief
+type BriefJSON struct {
+	City      string `json:"city"`
+	TermNow   int    `json:"term_now"`
+	FeelsNow  *int   `json:"feels_now,omitempty"`
+	Date      string `json:"date,omitempty"`
+	TempDay   *int   `json:"temp_day"`
+	TempNight *int   `json:"temp_night"`
 }
 
 // HourTemp - one hour temperature
@@ -56,24 +233,83 @@ type DayForecast struct {
 	Desc      string `json:"desc"`
 	Temp      int    `json:"temp"`
 	TempNight int    `json:"temp_night"`
+	TempFeels *int   `json:"temp_feels"`
+	// temperatures which are absent on the page, they are null in JSON
+	noTemp      bool
+	noTempNight bool
+}
+
+// dayForecastJSON - one day forecast in JSON, all keys are present for each day, absent values are null
+type dayForecastJSON struct {
+	Date      string `json:"date"`
+	Desc      string `json:"desc"`
+	Temp      *int   `json:"temp"`
+	TempNight *int   `json:"temp_night"`
+	TempFeels *int   `json:"temp_feels"`
 }
 
 var (
 	version   = "1.15"
 	userAgent = "yandex-weather-cli/" + version
+
+	errPageNotFound = errors.New("page not found")
+	errUnavailable  = errors.New("Yandex weather is temporarily unavailable")
 )
 
+// cityNotFoundError - error with message about not found city, it is errPageNotFound for errors.Is()
+type cityNotFoundError string
+
+func (e cityNotFoundError) Error() string {
+	return string(e)
+}
+
+func (e cityNotFoundError) Is(target error) bool {
+	return target == errPageNotFound
+}
+
 const (
 	// EnvBaseURLName - environment variable for setup base URL
 	EnvBaseURLName = "Y_WEATHER_URL"
 	// EnvBaseURLMiniName - environment variable for setup base URL (for days forecast)
 	EnvBaseURLMiniName = "Y_WEATHER_MINI_URL"
+	// EnvForceColorName - environment variable for colored output in pipe, as -color-when-piped
+	EnvForceColorName = "FORCE_COLOR"
+	// EnvAliasesFileName - environment variable for setup city aliases file
+	EnvAliasesFileName = "Y_WEATHER_ALIASES"
+	// AliasesFileDefault - city aliases file in user config directory
+	AliasesFileDefault = "yandex-weather-cli/aliases"
 	// BaseURLDefault - yandex pogoda service url (testing: "http://localhost:8080/get?url=https://yandex.ru/pogoda/")
 	BaseURLDefault = "https://yandex.ru/pogoda/"
 	// BaseURLMiniDefault - url for forecast by hours (testing: "http://localhost:8080/get?url=https://p.ya.ru/")
 	BaseURLMiniDefault = "https://p.ya.ru/"
+	// DNSRetryCount - attempts to fetch page on temporary DNS errors
+	DNSRetryCount = 3
+	// DNSRetryDelay - delay between attempts on temporary DNS errors
+	DNSRetryDelay = time.Second
+	// RetryAfterMax - maximum delay from Retry-After header for -retry-status
+	RetryAfterMax = time.Minute
+	// HumidityDryMax - maximum humidity (%) for "dry" comfort level
+	HumidityDryMax = 30
+	// HumidityHumidMin - minimum humidity (%) for "humid" comfort level
+	HumidityHumidMin = 60
+	// ExitCodeUnavailable - exit code if Yandex weather shows technical works page
+	ExitCodeUnavailable = 3
+	// ExitCodeAmbiguous - exit code if several cities are found by name
+	ExitCodeAmbiguous = 4
+	// ExitCodeAlertBelow - exit code if current temperature is below -alert-below
+	ExitCodeAlertBelow = 10
+	// ExitCodeAlertAbove - exit code if current temperature is above -alert-above
+	ExitCodeAlertAbove = 11
+	// ThemeDefault - default color theme
+	ThemeDefault = "dark"
+	// ForecastTableFixedWidth - forecast table width without description column
+	ForecastTableFixedWidth = 27
 	// TodayForecastTableWidth - today forecast table width for align tables
-	TodayForecastTableWidth = 14*4 - 27
+	TodayForecastTableWidth = 14*4 - ForecastTableFixedWidth
+	// AverageDays - max count of days for average temperature with -avg
+	AverageDays = 7
+	// SparklineLevelsASCII - symbols for sparkline levels with -ascii, from the lowest (as HistoChars)
+	SparklineLevelsASCII = "_.-=+*%#"
 )
 
 // Selectors - css selectors for forecast today
@@ -84,6 +320,25 @@ var Selectors = map[string]string{
 	"wind":     "div.fact div.fact__props div.fact__wind-speed",
 	"humidity": "div.fact div.fact__props div.fact__humidity",
 	"pressure": "div.fact div.fact__props div.fact__pressure",
+	// optional, icon with pressure trend
+	"pressure_trend": "div.fact div.fact__props div.fact__pressure i.icon:attr(class)",
+	// optional, local time in the city with timezone
+	"local_time": "div.fact time.fact__time:attr(datetime)",
+	// optional, speed of wind gusts
+	"wind_gust": "div.fact div.fact__props div.fact__wind-gust",
+	// optional, feels like temperature
+	"feels_now": "div.fact div.fact__feels-like span.temp__value",
+	// optional, time of observation of current weather: "Наблюдалось в 14:00"
+	"observed_at": "div.fact div.fact__observation",
+}
+
+// SelectorPreciseValues - block of current weather with precise values in data-value attributes (html2data can't get attributes with "-")
+var SelectorPreciseValues = "div.fact div.fact__props:html"
+
+// PreciseValueClasses - css classes of elements with precise values in data-value attribute
+var PreciseValueClasses = map[string]string{
+	"pressure_precise": "fact__pressure",
+	"humidity_precise": "fact__humidity",
 }
 
 // SelectorsNextDays - css selectors for forecast next days
@@ -92,6 +347,8 @@ var SelectorsNextDays = map[string]string{
 	"desc":       "div.forecast-briefly__days div.forecast-briefly__condition",
 	"temp":       "div.forecast-briefly__days div.forecast-briefly__temp_day span.temp__value",
 	"temp_night": "div.forecast-briefly__days div.forecast-briefly__temp_night span.temp__value",
+	// optional, feels like temperature
+	"temp_feels": "div.forecast-briefly__days div.forecast-briefly__temp_feels span.temp__value",
 }
 
 // SelectorByHoursRoot - Root element for forecast data
@@ -104,6 +361,69 @@ var SelectorByHours = map[string]string{
 	"icon": "i.icon:attr(class)",
 }
 
+// ObservedTimeRe - time in text of observation time
+var ObservedTimeRe = regexp.MustCompile(`\b\d{1,2}:\d{2}\b`)
+
+// HumidityComfortRu - humidity comfort levels for text output
+var HumidityComfortRu = map[string]string{
+	"dry":         "сухо",
+	"comfortable": "комфортно",
+	"humid":       "влажно",
+}
+
+// Themes - color themes, map semantic roles to ansi colors, empty color - without color
+var Themes = map[string]map[string]string{
+	"dark": {
+		"value":   "green",
+		"link":    "yellow",
+		"header":  "blue+h",
+		"hours":   "grey+h",
+		"icon":    "blue",
+		"weekend": "red+h",
+		"snow":    "white+h",
+		"rain":    "blue+h",
+	},
+	"light": {
+		"value":   "22",
+		"link":    "94",
+		"header":  "18+b",
+		"hours":   "240",
+		"icon":    "25",
+		"weekend": "124+b",
+		"snow":    "60",
+		"rain":    "21",
+	},
+	"mono": {
+		"value":   "",
+		"link":    "",
+		"header":  "",
+		"hours":   "",
+		"icon":    "",
+		"weekend": "",
+		"snow":    "",
+		"rain":    "",
+	},
+}
+
+// PressureTrendArrows - arrows for pressure trend
+var PressureTrendArrows = map[string]string{
+	"rising":  "↑",
+	"falling": "↓",
+	"steady":  "→",
+}
+
+// ForecastColumns - all columns of forecast table
+var ForecastColumns = []string{"date", "temp", "desc", "temp_night", "temp_feels"}
+
+// ForecastColumnsDefault - columns of forecast table by default, in this order
+var ForecastColumnsDefault = []string{"date", "temp", "desc", "temp_night"}
+
+// ConditionColorRoles - theme roles for colors of descriptions by condition
+var ConditionColorRoles = map[string]string{
+	"icon_snow": "snow",
+	"icon_rain": "rain",
+}
+
 // ICONS - unicode symbols for icon names
 var ICONS = map[string]string{
 	"icon_snow": "✻",
@@ -117,36 +437,194 @@ func outputIsPiped() bool {
 	return err != nil || (stdoutStat.Mode()&os.ModeCharDevice) == 0
 }
 
+//-----------------------------------------------------------------------------
+// check value of FORCE_COLOR environment variable: any except empty, "0" and "false"
+func isForceColor(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------
+// check if program's input is a terminal, for interactive questions
+func inputIsTerminal() bool {
+	stdinStat, err := os.Stdin.Stat()
+	return err == nil && (stdinStat.Mode()&os.ModeCharDevice) != 0
+}
+
 //-----------------------------------------------------------------------------
 // get command line parameters
 func getParams() (cfg Config) {
 	flag.BoolVar(&cfg.getJSON, "json", false, "get JSON")
+	flag.BoolVar(&cfg.jsonFlat, "json-flat", false, `JSON without next_days array, with "day1_temp", "day1_desc"... keys instead`)
+	flag.BoolVar(&cfg.jsonArray, "json-array-always", false, "wrap JSON for one city in array, as for -cities-file")
+	flag.BoolVar(&cfg.meta, "meta", false, "add _meta object to JSON with found and empty selectors")
+	flag.BoolVar(&cfg.compactJSON, "compact-json", false, "omit keys with empty values in JSON")
+	flag.BoolVar(&cfg.jsonIndent, "json-indent", false, "pretty-print JSON with indentation")
+	flag.StringVar(&cfg.citiesFile, "cities-file", "", "get weather for all cities from file, one city on each line")
+	flag.StringVar(&cfg.fallback, "fallback", "", "get weather from another source if Yandex is unavailable (wttr)")
+	flag.BoolVar(&cfg.interactive, "interactive", false, "if city is not given, search city while its name is typed and choose it by arrow keys, on terminal only")
+	flag.BoolVar(&cfg.fuzzy, "fuzzy", false, "if city is not found, try transliterated variants of its name")
+	flag.StringVar(&cfg.htmlFile, "file", "", "parse weather from local HTML file instead of network (for development and testing)")
+	flag.StringVar(&cfg.coords, "coords", "", "latitude and longitude of place (e.g. 55.75,37.62), instead of city name")
+	flag.StringVar(&cfg.cityID, "id", "", "Yandex numeric city ID (e.g. 213 for Moscow), instead of city name")
+	flag.BoolVar(&cfg.ipv4, "ipv4", false, "use only IPv4 for connections")
+	flag.StringVar(&cfg.alsoJSON, "also-json", "", "also write JSON to file")
+	flag.BoolVar(&cfg.appendJSON, "append", false, "append JSON to file from -also-json instead of overwrite")
 	flag.BoolVar(&cfg.noColor, "no-color", false, "disable colored output")
+	flag.BoolVar(&cfg.colorWhenPiped, "color-when-piped", false, "colored output even if it is not a terminal, e.g. for CI logs (or set "+EnvForceColorName+"=1)")
+	flag.BoolVar(&cfg.noLink, "no-link", false, "don't show link to the weather page after city name")
+	flag.StringVar(&cfg.theme, "theme", ThemeDefault, "color theme: "+strings.Join(getThemeNames(), ", "))
+	flag.BoolVar(&cfg.noStale, "no-stale", false, "disable showing of the last cached weather on network errors")
 	flag.BoolVar(&cfg.noToday, "no-today", false, "disable today forecast")
+	flag.BoolVar(&cfg.noCurrent, "no-current", false, "disable current weather")
+	flag.StringVar(&cfg.weekdayStyle, "weekday-style", "short", "style of weekday in forecast for next days: short (пн), long (понедельник)")
+	flag.BoolVar(&cfg.tempsOnly, "temps-only", false, "show forecast for next days as one line of day/night temperatures")
+	flag.StringVar(&cfg.forecastFormat, "forecast-format", "table", "format of forecast for next days: table or list")
+	flag.BoolVar(&cfg.repeatHeader, "repeat-header", false, "with -cities-file show header of forecast table for each city, not only for the first")
+	flag.StringVar(&cfg.separator, "sep", "", `separator of columns in forecast table, without align ("\t" - tab)`)
+	flag.BoolVar(&cfg.pressureTrend, "pressure-trend", false, "if the page has no pressure trend, get it by pressure saved an hour or more ago")
+	flag.BoolVar(&cfg.noPressure, "no-pressure", false, "don't show pressure")
+	flag.BoolVar(&cfg.noHumidity, "no-humidity", false, "don't show humidity")
+	flag.BoolVar(&cfg.noWind, "no-wind", false, "don't show wind")
+	flag.BoolVar(&cfg.noHeader, "no-header", false, "disable header of forecast table")
 	flag.IntVar(&cfg.daysLimit, "days", 10, "maximum days to show")
+	flag.BoolVar(&cfg.weekends, "weekends", false, "show only saturdays and sundays in forecast for next days (within -days)")
+	flag.IntVar(&cfg.skipDays, "skip", 0, "skip first days in forecast")
+	columns := flag.String("columns", strings.Join(ForecastColumnsDefault, ","), "columns of forecast table, in this order: "+strings.Join(ForecastColumns, ", "))
+	flag.IntVar(&cfg.minWidth, "min-width", 0, "minimum width of forecast table, description is widened to it (-max-width has precedence)")
+	flag.IntVar(&cfg.maxWidth, "max-width", 0, "maximum width of weather description in forecast table (0 - by terminal width)")
+	flag.BoolVar(&cfg.precision, "precision", false, "add numeric pressure_value and humidity_value to JSON, with full precision if the page has it")
+	flag.BoolVar(&cfg.prometheus, "prometheus", false, "get current weather as Prometheus metrics")
+	flag.IntVar(&cfg.retries, "retries", 0, "retry failed network requests N times")
+	retryStatus := flag.String("retry-status", "", `retry requests with these HTTP status codes too, with -retries, e.g. "429,503" (Retry-After header is respected)`)
+	flag.BoolVar(&cfg.strict, "strict", false, "exit with error if any of not optional fields is empty after parsing")
+	flag.IntVar(&cfg.retryOnEmpty, "retry-on-empty", 0, "fetch the page again N times if it has no weather (not loaded yet)")
+	flag.StringVar(&cfg.acceptLanguage, "accept-language", "", `value of Accept-Language header for requests to Yandex (e.g. "en", "uk, ru;q=0.8")`)
+	cfg.headers = headerFlags{}
+	flag.Var(cfg.headers, "header", `add HTTP header to requests to Yandex, "Key: Value", can be repeated`)
+	flag.DurationVar(&cfg.connectTimeout, "connect-timeout", 0, "timeout for connect to server, with DNS lookup (0 - default 30s)")
+	flag.DurationVar(&cfg.timeout, "timeout", 0, "timeout for each HTTP request (0 - without timeout)")
+	flag.DurationVar(&cfg.deadline, "deadline", 0, "deadline for the whole operation, all requests and parsing (0 - without deadline)")
+	flag.BoolVar(&cfg.swapDayNight, "swap-day-night", false, "swap day and night temperatures if day one is lower in most days")
+	flag.BoolVar(&cfg.transliterate, "transliterate", false, "convert russian text output to latin, for terminals without UTF-8")
+	flag.BoolVar(&cfg.unicodeMinus, "unicode-minus", false, "use unicode minus sign (−) for temperatures in text output")
+	flag.BoolVar(&cfg.notify, "notify", false, "show desktop notification with current weather")
+	flag.BoolVar(&cfg.toSyslog, "syslog", false, "write current weather summary to syslog")
+	flag.BoolVar(&cfg.average, "avg", false, "show average day temperature for the next 7 days (or less with -days)")
+	flag.BoolVar(&cfg.brief, "brief", false, "show only current and feels like temperature, day and night temperature for the first day of forecast")
+	flag.BoolVar(&cfg.summary, "summary", false, "show range of day temperatures for forecast period")
+	flag.BoolVar(&cfg.sparkline, "sparkline", false, "show sparkline of day temperatures under forecast for next days")
+	flag.BoolVar(&cfg.ascii, "ascii", false, "use only ASCII symbols for sparkline")
+	flag.BoolVar(&cfg.feels, "feels", false, "show feels like temperature in forecast for next days, if the page has it")
+	flag.BoolVar(&cfg.dewPoint, "dewpoint", false, "show dew point")
+	flag.BoolVar(&cfg.comfort, "comfort", false, "show humidity comfort level")
+	flag.BoolVar(&cfg.timing, "timing", false, "print time of fetch, parse and render to stderr")
+	flag.BoolVar(&cfg.verbose, "verbose", false, "print details about requests and cache to stderr")
+	flag.BoolVar(&cfg.colorTest, "color-test", false, "print all colors of theme with ansi codes and exit")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "print URLs and headers of requests for city (or for each city from -cities-file) and exit")
+	flag.BoolVar(&cfg.check, "check", false, "check that weather page is parsed correctly, exit with code 1 if not")
+	flag.BoolVar(&cfg.checkSelectors, "check-selectors", false, "print count of found values and sample value for each selector, exit with code 1 as -check")
+	flag.StringVar(&cfg.celsiusSymbol, "celsius-symbol", "°C", "degree symbol in text output: °C, C or none")
 	flag.Usage = func() {
 		fmt.Printf("Usage: %s [options] [city]\noptions:\n", os.Args[0])
 		flag.PrintDefaults()
 		fmt.Printf("\nexamples:\n  %s kyiv\n  %s -json london\n", os.Args[0], os.Args[0])
+		fmt.Printf("\nall options by groups with examples: %s -help-all\n", os.Args[0])
 	}
+	alertBelow := flag.Int("alert-below", 0, fmt.Sprintf("exit with code %d if current temperature is below this value", ExitCodeAlertBelow))
+	alertAbove := flag.Int("alert-above", 0, fmt.Sprintf("exit with code %d if current temperature is above this value", ExitCodeAlertAbove))
+	maxRetriesTotal := flag.Int("max-retries-total", 0, "maximum number of retries for all cities together, with -retries and -retry-on-empty (0 - without limit)")
+	snapshotDir := flag.String("snapshot", "", "save received pages and parsed weather as JSON to new timestamped directory in this one, for test fixtures")
+	templateText := flag.String("template", "", `format of output as Go template, e.g. "{{.City}}: {{color \"value\" .TermNow}}"`)
 	getVersion := flag.Bool("version", false, "get version")
+	helpAll := flag.Bool("help-all", false, "show all options by groups with examples")
 	flag.Parse()
 
+	// alerts are only for thresholds set explicitly, 0 is a valid one
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "alert-below":
+			cfg.alertBelow = alertBelow
+		case "alert-above":
+			cfg.alertAbove = alertAbove
+		}
+	})
+
 	if *getVersion {
 		fmt.Println(version)
 		os.Exit(0)
 	}
+	if *helpAll {
+		printHelpAll(os.Stdout, flag.CommandLine, os.Args[0])
+		os.Exit(0)
+	}
 
-	cfg.city = ""
-	if flag.NArg() >= 1 {
-		cfg.city = flag.Args()[0]
+	if _, ok := CelsiusSymbols[cfg.celsiusSymbol]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown celsius symbol %q, use one of: °C, C, none\n", cfg.celsiusSymbol)
+		os.Exit(1)
 	}
 
-	if runtime.GOOS == "windows" {
-		// broken unicode symbols in cmd.exe and don't detect pipe
-		cfg.noToday = true
-	} else if outputIsPiped() {
-		cfg.noColor = true
+	if cfg.forecastFormat != "table" && cfg.forecastFormat != "list" {
+		fmt.Fprintf(os.Stderr, "Unknown forecast format %q, use one of: table, list\n", cfg.forecastFormat)
+		os.Exit(1)
+	}
+
+	if cfg.weekdayStyle != "short" && cfg.weekdayStyle != "long" {
+		fmt.Fprintf(os.Stderr, "Unknown weekday style %q, use one of: short, long\n", cfg.weekdayStyle)
+		os.Exit(1)
+	}
+
+	var unknownColumn string
+	if cfg.columns, unknownColumn = parseColumns(*columns); unknownColumn != "" {
+		fmt.Fprintf(os.Stderr, "Unknown column %q, use: %s\n", unknownColumn, strings.Join(ForecastColumns, ", "))
+		os.Exit(1)
+	}
+	if len(cfg.columns) == 0 {
+		cfg.columns = append([]string{}, ForecastColumnsDefault...)
+	}
+
+	var wrongStatus string
+	if cfg.retryStatus, wrongStatus = parseRetryStatus(*retryStatus); wrongStatus != "" {
+		fmt.Fprintf(os.Stderr, "Wrong HTTP status code %q in -retry-status, use list of codes, e.g. 429,503\n", wrongStatus)
+		os.Exit(1)
+	}
+	cfg.separator = strings.Replace(cfg.separator, `\t`, "\t", -1)
+	if cfg.feels && !hasString(cfg.columns, "temp_feels") {
+		cfg.columns = append(cfg.columns, "temp_feels")
+	}
+	if cfg.maxWidth == 0 {
+		// terminal width without other columns of forecast table
+		if width, reserved := getTerminalWidth(), forecastTableWidth(cfg.columns, 0, cfg); width > reserved {
+			cfg.maxWidth = width - reserved
+		}
+	}
+
+	if cfg.skipDays < 0 {
+		fmt.Fprintln(os.Stderr, "Number of days to skip must not be negative")
+		os.Exit(1)
+	}
+
+	if _, ok := Themes[cfg.theme]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown theme %q, use one of: %s\n", cfg.theme, strings.Join(getThemeNames(), ", "))
+		os.Exit(1)
+	}
+
+	if cfg.acceptLanguage != "" && !isAcceptLanguage(cfg.acceptLanguage) {
+		fmt.Fprintf(os.Stderr, "Unknown Accept-Language %q, use language tags with optional weights: \"en\", \"uk, ru;q=0.8\"\n", cfg.acceptLanguage)
+		os.Exit(1)
+	}
+
+	if _, ok := FallbackSources[cfg.fallback]; cfg.fallback != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unknown fallback source %q, use: wttr\n", cfg.fallback)
+		os.Exit(1)
+	}
+
+	if cfg.noCurrent && (cfg.prometheus || cfg.noToday && cfg.daysLimit <= 0) {
+		fmt.Fprintln(os.Stderr, "Nothing to show: -no-current used with -prometheus or with -no-today and -days 0")
+		os.Exit(1)
 	}
 
 	if baseURL := os.Getenv(EnvBaseURLName); len(baseURL) > 0 {
@@ -160,235 +638,1909 @@ func getParams() (cfg Config) {
 		cfg.baseURLMini = BaseURLMiniDefault
 	}
 
-	return cfg
-}
-
-//-----------------------------------------------------------------------------
-// parse html via goquery, find DOM-nodes with weather forecast data
-func getWeather(cfg Config) (map[string]interface{}, []HourTemp, []DayForecast) {
-	forecastNow := map[string]interface{}{}
-	forecastNext := []DayForecast{}
-	forecastByHours := []HourTemp{}
-
-	reRemoveDesc := regexp.MustCompile(`^.+\s*:\s*`)
-	reRemoveMultiline := regexp.MustCompile(`\n.+$`)
-	reDate := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	cfg.city = ""
+	if flag.NArg() >= 1 {
+		aliases := getAliases()
+		cfg.city = normalizeCity(citySlug(flag.Args()[0], cfg.baseURL))
+		if city, ok := aliases[flag.Args()[0]]; ok {
+			cfg.city = city
+		} else if city, ok := aliases[cfg.city]; ok {
+			cfg.city = city
+		}
+		cfg.cityName, cfg.city = cfg.city, normalizeCitySlug(cfg.city)
+	}
 
-	var extractNowForecast = func(doc html2data.Doc) {
-		data, err := doc.GetDataFirst(Selectors)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+	if cfg.cityID != "" {
+		if flag.NArg() >= 1 {
+			fmt.Fprintln(os.Stderr, "Use either -id or city name, not both")
 			os.Exit(1)
 		}
-
-		for name := range Selectors {
-			forecastNow[name] = clearNonprintInString(data[name])
-			switch name {
-			case "city":
-				forecastNow[name] = reRemoveMultiline.ReplaceAllString(forecastNow[name].(string), "")
-			case "humidity", "pressure", "wind":
-				forecastNow[name] = reRemoveDesc.ReplaceAllString(forecastNow[name].(string), "")
-			case "term_now", "term_another_value1", "term_another_value2", "term_another_value3", "term_another_value4":
-				if value, ok := forecastNow[name]; ok {
-					forecastNow[name] = convertStrToInt(value.(string))
-				}
-			}
-			if name == "wind" && forecastNow[name] == nil {
-				forecastNow[name] = "0 м/с"
-			}
+		if !isCityID(cfg.cityID) {
+			fmt.Fprintf(os.Stderr, "City ID %q must be numeric\n", cfg.cityID)
+			os.Exit(1)
 		}
+		cfg.city = cfg.cityID
 	}
 
-	var extractNextForecast = func(doc html2data.Doc) {
-		dataNextDays, err := doc.GetData(SelectorsNextDays)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+	if cfg.coords != "" {
+		if flag.NArg() >= 1 || cfg.cityID != "" {
+			fmt.Fprintln(os.Stderr, "Use either -coords or city name (-id), not both")
+			os.Exit(1)
+		}
+		query, ok := coordsQuery(cfg.coords)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Coordinates %q must be in format LAT,LON (e.g. 55.75,37.62)\n", cfg.coords)
 			os.Exit(1)
 		}
+		cfg.city = query
+	}
 
-		if dateColumn, ok := dataNextDays["date"]; ok {
-			now := time.Now()
-		daysLoop:
-			for i, dateStr := range dateColumn {
-				if len(forecastNext) >= cfg.daysLimit {
-					break daysLoop
-				}
+	if runtime.GOOS == "windows" {
+		// broken unicode symbols in cmd.exe and don't detect pipe
+		cfg.noToday = true
+		cfg.ascii = true
+	} else if outputIsPiped() && !cfg.colorWhenPiped && !isForceColor(os.Getenv(EnvForceColorName)) {
+		cfg.noColor = true
+	}
 
-				if dateStr == "" {
-					continue
-				}
+	if *maxRetriesTotal > 0 {
+		cfg.retryBudget = &retryBudget{left: *maxRetriesTotal}
+	}
 
-				currentDay := DayForecast{}
-				for name := range SelectorsNextDays {
-					text := ""
-					if _, ok := dataNextDays[name]; ok && len(dataNextDays[name]) >= i+1 {
-						text = dataNextDays[name][i]
-					} else {
-						continue
-					}
-					text = clearNonprintInString(text)
-
-					switch name {
-					case "date":
-						datesRaw := reDate.FindAllString(text, 1)
-						if len(datesRaw) == 1 {
-							curDate, err := time.Parse("2006-01-02", datesRaw[0])
-							if err != nil || !curDate.Truncate(time.Hour*24).After(now.Truncate(time.Hour*24)) {
-								continue daysLoop
-							}
-							currentDay.DateHuman, currentDay.Date = formatDates(curDate)
-						}
-					case "desc":
-						currentDay.Desc = strings.ToLower(text)
-					case "temp":
-						currentDay.Temp = convertStrToInt(text)
-					case "temp_night":
-						currentDay.TempNight = convertStrToInt(text)
-					}
-				}
+	if *snapshotDir != "" {
+		cfg.snapshot = &pageSnapshot{dir: *snapshotDir}
+	}
 
-				if currentDay.Date != "" {
-					forecastNext = append(forecastNext, currentDay)
-				}
-			}
+	if *templateText != "" {
+		// after -no-color is set, color function of template uses it
+		outputTemplate, err := cfg.parseTemplate(*templateText)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid template: %s\n", err)
+			os.Exit(1)
 		}
+		cfg.outputTemplate = outputTemplate
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	go func() {
-		doc := html2data.FromURL(cfg.baseURL+cfg.city, html2data.URLCfg{UA: userAgent})
-		extractNowForecast(doc)
-		extractNextForecast(doc)
-		wg.Done()
-	}()
+	if charset := strings.ToLower(localeCharset()); charset != "" && charset != "utf-8" && charset != "utf8" && !cfg.transliterate && !cfg.getJSON {
+		fmt.Fprintf(os.Stderr, "warning: encoding of terminal is not UTF-8 (%s), try -transliterate\n", localeCharset())
+	}
 
-	go func() {
-		// forecast by hours block
-		if !cfg.noToday {
-			docMini := html2data.FromURL(cfg.baseURLMini+cfg.city, html2data.URLCfg{UA: userAgent})
-			dataHours, err := docMini.GetDataNestedFirst(SelectorByHoursRoot, SelectorByHours)
-			if err == nil {
-				for _, row := range dataHours {
-					hour := convertStrToInt(row["hour"])
-					temp := convertStrToInt(row["temp"])
-					forecastByHours = append(forecastByHours, HourTemp{Hour: hour, Temp: temp, Icon: parseIcon(row["icon"])})
-				}
-			}
-		}
+	return cfg
+}
 
-		wg.Done()
-	}()
+//-----------------------------------------------------------------------------
+// get page from -file if it is set, else from network
+func getPage(ctx context.Context, pageURL string, cfg Config) (html2data.Doc, string) {
+	defer cfg.logTiming("fetch "+pageURL, time.Now())
 
-	wg.Wait()
-	return forecastNow, forecastByHours, forecastNext
+	if cfg.htmlFile != "" {
+		return html2data.FromFile(cfg.htmlFile), "file://" + cfg.htmlFile
+	}
+	return getWeatherPage(ctx, pageURL, cfg)
 }
 
 //-----------------------------------------------------------------------------
-// get icon name from css class attribut
-func parseIcon(cssClass string) string {
-	allAttributes := regexp.MustCompile(`\s+`).Split(cssClass, -1)
-	for _, attr := range allAttributes {
-		if _, ok := ICONS[attr]; ok {
-			return attr
-		}
+// get headers of requests to Yandex, -header overrides default ones
+func requestHeaders(cfg Config) http.Header {
+	headers := http.Header{}
+	headers.Set("User-Agent", userAgent)
+	if cfg.acceptLanguage != "" {
+		headers.Set("Accept-Language", cfg.acceptLanguage)
 	}
-	return ""
+	for key, values := range cfg.headers {
+		headers[key] = values
+	}
+
+	return headers
 }
 
 //-----------------------------------------------------------------------------
-// render data as text or JSON
-func render(forecastNow map[string]interface{}, forecastByHours []HourTemp, forecastNext []DayForecast, cfg Config) {
-	cityFromPage, ok := forecastNow["city"]
-	if !ok || cityFromPage == "" {
-		fmt.Fprintf(os.Stderr, "City %q not found\n", cfg.city)
-		os.Exit(1)
+// get html page as html2data.Doc and final URL after redirects
+func getWeatherPage(ctx context.Context, pageURL string, cfg Config) (html2data.Doc, string) {
+	cookie, err := cookiejar.New(nil)
+	if err != nil {
+		return html2data.Doc{Err: err}, pageURL
 	}
-	outWriter := getColorWriter(cfg.noColor)
 
-	if cfg.getJSON {
-		if !cfg.noToday && len(forecastByHours) > 0 {
-			forecastNow["by_hours"] = forecastByHours
+	request, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return html2data.Doc{Err: err}, pageURL
+	}
+	request.Header = requestHeaders(cfg)
+
+	// conditional GET, page from cache is used if it is not modified
+	cached, cacheErr := loadPageCache(pageURL)
+	if cacheErr == nil {
+		if cached.ETag != "" {
+			request.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			request.Header.Set("If-Modified-Since", cached.LastModified)
 		}
+	}
 
-		if len(forecastNext) > 0 {
-			forecastNow["next_days"] = forecastNext
+	client := &http.Client{Jar: cookie, Timeout: cfg.timeout, Transport: getTransport(cfg)}
+	var response *http.Response
+	for attempt := 1; ; attempt++ {
+		response, err = client.Do(request)
+		if err == nil && (!hasInt(cfg.retryStatus, response.StatusCode) || attempt > cfg.retries) {
+			break
 		}
+		if err == nil {
+			// status from -retry-status, the page is requested again
+			response.Body.Close()
+			cfg.logVerbose("%s: %s, retry %d of %d", pageURL, response.Status, attempt, cfg.retries)
+			if !cfg.retryBudget.take() {
+				return html2data.Doc{Err: fmt.Errorf("%s: %s (no retries left, -max-retries-total is exhausted)", pageURL, response.Status)}, pageURL
+			}
 
-		jsonBytes, _ := json.Marshal(forecastNow)
-		fmt.Println(string(jsonBytes))
-		return
+			select {
+			case <-time.After(retryAfterDelay(response.Header.Get("Retry-After"), time.Now(), DNSRetryDelay)):
+			case <-ctx.Done():
+				return html2data.Doc{Err: deadlineError(ctx, cfg, fmt.Errorf("%s: %s", pageURL, response.Status))}, pageURL
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return html2data.Doc{Err: deadlineError(ctx, cfg, err)}, pageURL
+		}
+
+		temporary, dnsErr := checkDNSError(err)
+		switch {
+		case !temporary && !isDNSError(err) && isNetworkError(err) && attempt <= cfg.retries:
+			cfg.logVerbose("%s: %s, retry %d of %d", pageURL, err, attempt, cfg.retries)
+		case !temporary:
+			return html2data.Doc{Err: friendlyNetworkError(dnsErr, cfg)}, pageURL
+		case attempt >= DNSRetryCount && attempt > cfg.retries:
+			return html2data.Doc{Err: fmt.Errorf("%w (gave up after %d attempts)", dnsErr, attempt)}, pageURL
+		}
+		if !cfg.retryBudget.take() {
+			return html2data.Doc{Err: fmt.Errorf("%w (no retries left, -max-retries-total is exhausted)", friendlyNetworkError(dnsErr, cfg))}, pageURL
+		}
+
+		select {
+		case <-time.After(DNSRetryDelay):
+		case <-ctx.Done():
+			return html2data.Doc{Err: deadlineError(ctx, cfg, dnsErr)}, pageURL
+		}
 	}
+	defer response.Body.Close()
 
-	outWriter.Printf(cfg.ansiColourString("%s (<yellow>%s</>)\n"), cityFromPage, cfg.baseURL+cfg.city)
-	outWriter.Printf(
-		cfg.ansiColourString("Сейчас: <green>%d °C</> - <green>%s</>\n"),
-		forecastNow["term_now"],
-		forecastNow["desc_now"],
-	)
+	finalURL := response.Request.URL.String()
+	if response.StatusCode == http.StatusNotModified && cacheErr == nil {
+		cfg.logVerbose("%s: not modified, using cached page", pageURL)
+		cfg.snapshot.add(pageURL, []byte(cached.Body))
+		return html2data.FromReader(strings.NewReader(cached.Body)), finalURL
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return html2data.Doc{Err: fmt.Errorf("%w: %s", errPageNotFound, finalURL)}, finalURL
+	}
+	if response.StatusCode == http.StatusServiceUnavailable {
+		return html2data.Doc{Err: errUnavailable}, finalURL
+	}
+	contentType := response.Header.Get("Content-Type")
+	if !isHTMLContentType(contentType) {
+		return html2data.Doc{Err: fmt.Errorf("unexpected content type: %s (%s)", contentType, finalURL)}, finalURL
+	}
 
-	outWriter.Printf(cfg.ansiColourString("Давление: <green>%s</>\n"), forecastNow["pressure"])
-	outWriter.Printf(cfg.ansiColourString("Влажность: <green>%s</>\n"), forecastNow["humidity"])
-	outWriter.Printf(cfg.ansiColourString("Ветер: <green>%s</>\n"), forecastNow["wind"])
+	htmlReader, err := charset.NewReader(response.Body, contentType)
+	if err != nil {
+		return html2data.Doc{Err: err}, finalURL
+	}
 
-	if !cfg.noToday && len(forecastByHours) > 0 {
-		textByHour := [4]string{}
-		for _, item := range forecastByHours {
-			textByHour[0] += fmt.Sprintf("%3d ", item.Hour)
-			textByHour[2] += fmt.Sprintf("%3d°", item.Temp)
-			icon, exists := ICONS[item.Icon]
-			if !exists {
-				icon = " "
-			}
-			textByHour[3] += fmt.Sprintf(cfg.ansiColourString("<blue>%3s</blue> "), icon)
+	body, err := ioutil.ReadAll(htmlReader)
+	if err != nil {
+		return html2data.Doc{Err: deadlineError(ctx, cfg, err)}, finalURL
+	}
+
+	if response.StatusCode == http.StatusOK {
+		page := cachedPage{ETag: response.Header.Get("ETag"), LastModified: response.Header.Get("Last-Modified"), Body: string(body)}
+		if err := savePageCache(pageURL, page); err != nil {
+			cfg.logVerbose("%s: failed to save page to cache: %s", pageURL, err)
 		}
-		textByHour[1] = cfg.ansiColourString("<grey+h>" + renderHisto(forecastByHours) + "</>")
+	}
+	cfg.logVerbose("%s: HTTP %d, %d bytes", pageURL, response.StatusCode, len(body))
+	cfg.snapshot.add(pageURL, body)
 
-		outWriter.Println(strings.Repeat("─", len(forecastByHours)*4))
-		outWriter.Printf("%s\n%s\n%s\n%s\n",
-			cfg.ansiColourString("<grey+h>"+textByHour[0]+"</>"),
-			textByHour[1],
-			textByHour[2],
-			textByHour[3],
-		)
+	return html2data.FromReader(bytes.NewReader(body)), finalURL
+}
+
+//-----------------------------------------------------------------------------
+// check value of Accept-Language header: list of language tags with optional weights
+func isAcceptLanguage(value string) bool {
+	reLanguage := regexp.MustCompile(`^([A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*|\*)(\s*;\s*q=[01](\.\d{0,3})?)?$`)
+	for _, language := range strings.Split(value, ",") {
+		if !reLanguage.MatchString(strings.TrimSpace(language)) {
+			return false
+		}
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------
+// check that Content-Type header is for HTML page, empty header is allowed
+func isHTMLContentType(contentType string) bool {
+	if contentType == "" {
+		return true
 	}
 
-	if len(forecastNext) > 0 {
-		descLength := getMaxLengthDesc(forecastNext)
-		if descLength < TodayForecastTableWidth {
-			// align with today forecast
-			descLength = TodayForecastTableWidth
+	// broken parameters like charset are not important here
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil && err != mime.ErrInvalidMediaParameter {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+//-----------------------------------------------------------------------------
+// get HTTP transport, with dialing only via IPv4 if needed
+func getTransport(cfg Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ipv4 || cfg.connectTimeout > 0 {
+		// the same as in http.DefaultTransport, if it is not set
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if cfg.connectTimeout > 0 {
+			dialer.Timeout = cfg.connectTimeout
 		}
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if cfg.ipv4 {
+				network = "tcp4"
+			}
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
 
-		outWriter.Println(strings.Repeat("─", 27+descLength))
-		outWriter.Printf(
-			cfg.ansiColourString("<blue+h> %-10s %4s %-*s %8s</>\n"),
-			"дата",
-			"°C",
-			descLength, "погода",
-			"°C ночью",
-		)
-		outWriter.Println(strings.Repeat("─", 27+descLength))
+	return transport
+}
 
-		weekendRe := regexp.MustCompile(`(сб|вс)`)
-		for _, row := range forecastNext {
-			date := weekendRe.ReplaceAllString(row.DateHuman, cfg.ansiColourString("<red+h>$1</>"))
-			outWriter.Printf(
-				" %10s %3d° %-*s %7d°\n",
-				date,
-				row.Temp,
-				descLength,
-				row.Desc,
-				row.TempNight,
-			)
+//-----------------------------------------------------------------------------
+// check that URL is a page of region (like "https://yandex.ru/pogoda/region/225") instead of a city
+func isRegionPage(pageURL string) bool {
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	return regexp.MustCompile(`/region(/|$)`).MatchString(parsedURL.Path)
+}
+
+//-----------------------------------------------------------------------------
+// get other variants of city name for -fuzzy: transliterated and with "-" instead of spaces
+func getCityVariants(city string) []string {
+	result := []string{}
+	for _, variant := range []string{
+		strings.Join(strings.Fields(city), "-"),
+		transliterate(strings.Join(strings.Fields(city), "-")),
+	} {
+		if variant != city && variant != "" && !hasString(result, variant) {
+			result = append(result, variant)
 		}
 	}
+
+	return result
 }
 
 //-----------------------------------------------------------------------------
-func main() {
-	cfg := getParams()
-	forecastNow, forecastByHours, forecastNext := getWeather(cfg)
-	render(forecastNow, forecastByHours, forecastNext, cfg)
+// check that page is a stub about technical works instead of the weather
+func isMaintenancePage(doc html2data.Doc) bool {
+	text, err := doc.GetDataSingle("body")
+	if err != nil || !strings.Contains(strings.ToLower(text), "технические работы") {
+		return false
+	}
+
+	// the weather page itself can mention technical works, so check that there is no current weather
+	termNow, err := doc.GetDataSingle(Selectors["term_now"])
+	return err == nil && strings.TrimSpace(termNow) == ""
+}
+
+//-----------------------------------------------------------------------------
+// check that error is caused by network: timeout, connection or DNS error,
+// but not TLS/certificate errors, they are not fixed by retry
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if isTLSError(err) {
+		return false
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
+
+//-----------------------------------------------------------------------------
+// check that error is TLS alert or certificate error,
+// crypto/tls returns alerts as net.OpError with "remote error"/"local error" operation
+func isTLSError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "remote error" || opErr.Op == "local error") {
+		return true
+	}
+
+	var (
+		authorityErr x509.UnknownAuthorityError
+		invalidErr   x509.CertificateInvalidError
+		hostnameErr  x509.HostnameError
+	)
+	return errors.As(err, &authorityErr) || errors.As(err, &invalidErr) || errors.As(err, &hostnameErr)
+}
+
+//-----------------------------------------------------------------------------
+// check that error is caused by DNS, it has own messages and retries
+func isDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+//-----------------------------------------------------------------------------
+// add clear message to network error, with hint about -retries if it is not used
+func friendlyNetworkError(err error, cfg Config) error {
+	if !isNetworkError(err) || isDNSError(err) {
+		return err
+	}
+
+	hint := ""
+	if cfg.retries == 0 {
+		hint = " (try -retries N to repeat the request)"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("request timed out: %w%s", err, hint)
+	}
+	return fmt.Errorf("network error: %w%s", err, hint)
+}
+
+//-----------------------------------------------------------------------------
+// replace error to clear message if the whole operation deadline is exceeded
+func deadlineError(ctx context.Context, cfg Config, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("deadline %s exceeded, aborted", cfg.deadline)
+	}
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// check error for DNS resolution failure: temporary errors are worth to retry,
+// for "no such host" returns error with clear message
+func checkDNSError(err error) (bool, error) {
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return false, err
+	}
+
+	if dnsErr.IsNotFound {
+		return false, fmt.Errorf("host %q not found, check the URL: %w", dnsErr.Name, err)
+	}
+
+	return true, fmt.Errorf("temporary DNS failure for host %q: %w", dnsErr.Name, err)
+}
+
+//-----------------------------------------------------------------------------
+// get city aliases from file, returns empty map if file is not exists
+func getAliases() map[string]string {
+	fileName := os.Getenv(EnvAliasesFileName)
+	if fileName == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return map[string]string{}
+		}
+		fileName = filepath.Join(configDir, AliasesFileDefault)
+	}
+
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "warning: failed to read aliases: %s\n", err)
+		}
+		return map[string]string{}
+	}
+
+	return parseAliases(string(content))
+}
+
+//-----------------------------------------------------------------------------
+// parse html via goquery, find DOM-nodes with weather forecast data
+func getWeather(ctx context.Context, cfg Config) (map[string]interface{}, []HourTemp, []DayForecast, error) {
+	forecastNow := map[string]interface{}{}
+	forecastNext := []DayForecast{}
+	forecastByHours := []HourTemp{}
+
+	reRemoveDesc := regexp.MustCompile(`^.+\s*:\s*`)
+	reRemoveMultiline := regexp.MustCompile(`\n.+$`)
+	reRemoveBeforeNumber := regexp.MustCompile(`^\D+`)
+
+	// names of found and empty selectors for -meta and -strict
+	metaFound, metaEmpty := []string{}, []string{}
+	var addMeta = func(name string, found bool) {
+		if found {
+			metaFound = append(metaFound, name)
+		} else {
+			metaEmpty = append(metaEmpty, name)
+		}
+	}
+
+	var extractNowForecast = func(doc html2data.Doc) error {
+		if doc.Err != nil {
+			return doc.Err
+		}
+
+		data, err := doc.GetDataFirst(Selectors)
+		if err != nil {
+			return err
+		}
+
+		for name := range Selectors {
+			forecastNow[name] = clearNonprintInString(data[name])
+			switch name {
+			case "city":
+				forecastNow[name] = reRemoveMultiline.ReplaceAllString(forecastNow[name].(string), "")
+			case "humidity", "pressure", "wind":
+				forecastNow[name] = reRemoveDesc.ReplaceAllString(forecastNow[name].(string), "")
+			case "wind_gust":
+				// "Порывы до 8 м/с" -> "8 м/с"
+				forecastNow[name] = reRemoveBeforeNumber.ReplaceAllString(forecastNow[name].(string), "")
+			case "pressure_trend":
+				forecastNow[name] = parsePressureTrend(forecastNow[name].(string))
+			case "term_now", "term_another_value1", "term_another_value2", "term_another_value3", "term_another_value4":
+				if value, ok := forecastNow[name]; ok {
+					forecastNow[name] = convertStrToInt(value.(string))
+				}
+			case "feels_now":
+				// keep missing value as empty string, 0 is a valid temperature
+				if value := forecastNow[name].(string); value != "" {
+					forecastNow[name] = convertStrToInt(value)
+				}
+			}
+			if name == "wind" && forecastNow[name] == nil {
+				forecastNow[name] = "0 м/с"
+			}
+		}
+
+		for name := range Selectors {
+			addMeta(name, clearNonprintInString(data[name]) != "")
+		}
+
+		if cfg.precision {
+			if propsHTML, err := doc.GetDataSingle(SelectorPreciseValues); err == nil {
+				for name, value := range parsePreciseValues(propsHTML) {
+					forecastNow[name] = value
+				}
+			}
+		}
+
+		return nil
+	}
+
+	var extractNextForecast = func(doc html2data.Doc) error {
+		dataNextDays, err := doc.GetData(SelectorsNextDays)
+		if err != nil {
+			return err
+		}
+
+		// column with missing values for some days is empty too, for -strict
+		uneven := map[string]bool{}
+		for _, name := range getUnevenColumns(dataNextDays) {
+			uneven[name] = true
+		}
+		for name, values := range dataNextDays {
+			found := false
+			for _, value := range values {
+				found = found || clearNonprintInString(value) != ""
+			}
+			addMeta("next_days."+name, found && !uneven[name])
+		}
+
+		forecastNext = parseForecastNext(dataNextDays, cfg.skipDays+cfg.daysLimit)
+		// forecast can be empty after -skip and -weekends, it is not the same as absent on the page
+		forecastNow["next_days_on_page"] = len(forecastNext) > 0
+		if !cfg.allDays {
+			forecastNext = filterDays(forecastNext, cfg, time.Now())
+		}
+		if isDayNightInverted(forecastNext) {
+			if cfg.swapDayNight {
+				swapDayNight(forecastNext)
+			} else {
+				fmt.Fprintln(os.Stderr, "warning: day temperature is lower than night one in most days, try -swap-day-night")
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg  sync.WaitGroup
+		err error
+	)
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		doc, sourceURL := getPage(ctx, cfg.baseURL+cfg.city, cfg)
+		if errors.Is(doc.Err, errPageNotFound) {
+			if cfg.coords != "" {
+				err = cityNotFoundError(fmt.Sprintf("no weather page for coordinates %s (%s)", cfg.coords, sourceURL))
+			} else if isCityID(cfg.city) {
+				err = cityNotFoundError(fmt.Sprintf("city with ID %s not found (%s)", cfg.city, sourceURL))
+			} else {
+				err = cityNotFoundError(fmt.Sprintf("city %q not found (%s)", cfg.city, sourceURL))
+			}
+			return
+		}
+		if doc.Err == nil && isMaintenancePage(doc) {
+			err = errUnavailable
+			return
+		}
+		if candidates := getCityCandidates(doc); doc.Err == nil && len(candidates) > 0 {
+			err = cityCandidatesError{city: cfg.city, candidates: candidates}
+			return
+		}
+		if doc.Err == nil && isRegionPage(sourceURL) {
+			err = fmt.Errorf("%q is a region, not a city (%s), please specify a city in this region", cfg.city, sourceURL)
+			return
+		}
+		defer cfg.logTiming("parse", time.Now())
+		if err = extractNowForecast(doc); err != nil {
+			return
+		}
+		forecastNow["source_url"] = sourceURL
+		err = extractNextForecast(doc)
+	}()
+
+	go func() {
+		// forecast by hours block
+		if !cfg.noToday {
+			docMini, _ := getPage(ctx, cfg.baseURLMini+cfg.city, cfg)
+			dataHours, err := docMini.GetDataNestedFirst(SelectorByHoursRoot, SelectorByHours)
+			if err == nil {
+				for _, row := range dataHours {
+					hour := convertStrToInt(row["hour"])
+					temp := convertStrToInt(row["temp"])
+					forecastByHours = append(forecastByHours, HourTemp{Hour: hour, Temp: temp, Icon: parseIcon(row["icon"])})
+				}
+			}
+		}
+
+		wg.Done()
+	}()
+
+	wg.Wait()
+	if (cfg.meta || cfg.strict) && err == nil {
+		sort.Strings(metaFound)
+		sort.Strings(metaEmpty)
+		forecastNow["selectors_found"], forecastNow["selectors_empty"] = metaFound, metaEmpty
+	}
+	return forecastNow, forecastByHours, forecastNext, err
+}
+
+//-----------------------------------------------------------------------------
+// parse forecast for next days relative to the current time
+func parseForecastNext(dataNextDays map[string][]string, daysLimit int) []DayForecast {
+	return parseForecastNextAt(dataNextDays, daysLimit, time.Now())
+}
+
+//-----------------------------------------------------------------------------
+// parse forecast for next days, skip days before or equal to "now"
+func parseForecastNextAt(dataNextDays map[string][]string, daysLimit int, now time.Time) []DayForecast {
+	forecastNext := []DayForecast{}
+	reDate := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+	dateColumn, ok := dataNextDays["date"]
+	if !ok {
+		return forecastNext
+	}
+
+	if uneven := getUnevenColumns(dataNextDays); len(uneven) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: forecast columns %s have different length than dates (%d), missing values are empty\n",
+			strings.Join(uneven, ", "), len(dateColumn))
+	}
+
+daysLoop:
+	for i, dateStr := range dateColumn {
+		if len(forecastNext) >= daysLimit {
+			break daysLoop
+		}
+
+		if dateStr == "" {
+			continue
+		}
+
+		currentDay := DayForecast{}
+		for name := range SelectorsNextDays {
+			text := ""
+			if _, ok := dataNextDays[name]; ok && len(dataNextDays[name]) >= i+1 {
+				text = dataNextDays[name][i]
+			}
+			text = clearNonprintInString(text)
+
+			switch name {
+			case "date":
+				datesRaw := reDate.FindAllString(text, 1)
+				if len(datesRaw) == 1 {
+					curDate, err := time.Parse("2006-01-02", datesRaw[0])
+					if err != nil || !curDate.Truncate(time.Hour*24).After(now.Truncate(time.Hour*24)) {
+						continue daysLoop
+					}
+					currentDay.DateHuman, currentDay.Date = formatDates(curDate)
+				}
+			case "desc":
+				currentDay.Desc = strings.ToLower(text)
+			case "temp":
+				currentDay.Temp, currentDay.noTemp = convertStrToInt(text), text == ""
+			case "temp_night":
+				currentDay.TempNight, currentDay.noTempNight = convertStrToInt(text), text == ""
+			case "temp_feels":
+				if text != "" {
+					tempFeels := convertStrToInt(text)
+					currentDay.TempFeels = &tempFeels
+				}
+			}
+		}
+
+		if currentDay.Date != "" {
+			forecastNext = append(forecastNext, currentDay)
+		}
+	}
+
+	return forecastNext
+}
+
+//-----------------------------------------------------------------------------
+// MarshalJSON - day of forecast with the same keys for each day, absent temperatures are null
+func (day DayForecast) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dayForecastJSON{Date: day.Date, Desc: day.Desc, Temp: day.dayTemp(), TempNight: day.nightTemp(), TempFeels: day.TempFeels})
+}
+
+//-----------------------------------------------------------------------------
+// UnmarshalJSON - day of forecast from JSON (e.g. from cache), null temperatures are absent
+func (day *DayForecast) UnmarshalJSON(data []byte) error {
+	result := dayForecastJSON{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+
+	*day = DayForecast{Date: result.Date, Desc: result.Desc, TempFeels: result.TempFeels, noTemp: result.Temp == nil, noTempNight: result.TempNight == nil}
+	if result.Temp != nil {
+		day.Temp = *result.Temp
+	}
+	if result.TempNight != nil {
+		day.TempNight = *result.TempNight
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// get day temperature, nil if it is absent on the page
+func (day DayForecast) dayTemp() *int {
+	if day.noTemp {
+		return nil
+	}
+	return &day.Temp
+}
+
+//-----------------------------------------------------------------------------
+// get night temperature, nil if it is absent on the page
+func (day DayForecast) nightTemp() *int {
+	if day.noTempNight {
+		return nil
+	}
+	return &day.TempNight
+}
+
+//-----------------------------------------------------------------------------
+// check that the page has forecast for next days, before -skip and -weekends
+func hasNextDaysOnPage(forecastNow map[string]interface{}) bool {
+	onPage, _ := forecastNow["next_days_on_page"].(bool)
+	return onPage
+}
+
+//-----------------------------------------------------------------------------
+// filter forecast for next days by -days, -skip and -weekends,
+// days before or equal to "now" are skipped too, e.g. in forecast from cache
+func filterDays(forecastNext []DayForecast, cfg Config, now time.Time) []DayForecast {
+	result := []DayForecast{}
+	for _, day := range forecastNext {
+		if day.Date == "" || day.Date > now.Format("2006-01-02") {
+			result = append(result, day)
+		}
+	}
+	if len(result) > cfg.skipDays+cfg.daysLimit {
+		result = result[:cfg.skipDays+cfg.daysLimit]
+	}
+
+	result = skipDays(result, cfg.skipDays)
+	if cfg.weekends {
+		result = weekendDays(result)
+	}
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// skip first days in forecast
+func skipDays(forecastNext []DayForecast, skip int) []DayForecast {
+	if skip >= len(forecastNext) {
+		return []DayForecast{}
+	}
+	return forecastNext[skip:]
+}
+
+//-----------------------------------------------------------------------------
+// get only saturdays and sundays from forecast, for -weekends
+func weekendDays(forecastNext []DayForecast) []DayForecast {
+	result := []DayForecast{}
+	for _, day := range forecastNext {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err == nil && (date.Weekday() == time.Saturday || date.Weekday() == time.Sunday) {
+			result = append(result, day)
+		}
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// check that day temperature is lower than night one in most days (with both temperatures),
+// it is a sign of changed order of elements on the page
+func isDayNightInverted(forecastNext []DayForecast) bool {
+	inverted, days := 0, 0
+	for _, day := range forecastNext {
+		if day.noTemp || day.noTempNight {
+			continue
+		}
+		days++
+		if day.Temp < day.TempNight {
+			inverted++
+		}
+	}
+
+	return days > 0 && inverted > days/2
+}
+
+//-----------------------------------------------------------------------------
+// swap day and night temperatures in forecast
+func swapDayNight(forecastNext []DayForecast) {
+	for i := range forecastNext {
+		forecastNext[i].Temp, forecastNext[i].TempNight = forecastNext[i].TempNight, forecastNext[i].Temp
+		forecastNext[i].noTemp, forecastNext[i].noTempNight = forecastNext[i].noTempNight, forecastNext[i].noTemp
+	}
+}
+
+//-----------------------------------------------------------------------------
+// get names of forecast columns which length differs from dates column
+func getUnevenColumns(dataNextDays map[string][]string) []string {
+	result := []string{}
+	for name := range SelectorsNextDays {
+		if OptionalSelectors[name] && len(dataNextDays[name]) == 0 {
+			continue
+		}
+		if len(dataNextDays[name]) != len(dataNextDays["date"]) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get icon name from css class attribut
+func parseIcon(cssClass string) string {
+	allAttributes := regexp.MustCompile(`\s+`).Split(cssClass, -1)
+	for _, attr := range allAttributes {
+		if _, ok := ICONS[attr]; ok {
+			return attr
+		}
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// get condition (the same names as for icons) from text description, empty if unknown
+func descCondition(desc string) string {
+	desc = strings.ToLower(desc)
+	switch {
+	case strings.Contains(desc, "снег"), strings.Contains(desc, "метел"):
+		return "icon_snow"
+	case strings.Contains(desc, "дожд"), strings.Contains(desc, "ливень"), strings.Contains(desc, "ливн"):
+		return "icon_rain"
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// get pressure trend ("rising", "falling", "steady") from css class attribute, empty if unknown
+func parsePressureTrend(cssClass string) string {
+	for _, attr := range regexp.MustCompile(`[\s_-]+`).Split(cssClass, -1) {
+		switch attr {
+		case "up":
+			return "rising"
+		case "down":
+			return "falling"
+		case "steady", "stable":
+			return "steady"
+		}
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// parse local time from the page, like "2021-06-28T12:36:00+03:00" or "2021-06-28 12:36+0300"
+func parseLocalTime(str string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04-0700", "2006-01-02T15:04-0700", "2006-01-02 15:04:05-0700"} {
+		if localTime, err := time.Parse(layout, str); err == nil {
+			return localTime, true
+		}
+	}
+	return time.Time{}, false
+}
+
+//-----------------------------------------------------------------------------
+// get "HH:MM" of observation from text like "Наблюдалось в 14:00", empty if there is no time
+func observedClock(observed string) string {
+	return ObservedTimeRe.FindString(observed)
+}
+
+//-----------------------------------------------------------------------------
+// get full time of observation by time in text and local time in the city,
+// observation is before local time, so it can be on the previous day
+func parseObservedTime(observed, localTime string) (time.Time, bool) {
+	local, ok := parseLocalTime(localTime)
+	clock, err := time.Parse("15:04", observedClock(observed))
+	if !ok || err != nil {
+		return time.Time{}, false
+	}
+
+	result := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, local.Location())
+	if result.After(local) {
+		result = result.AddDate(0, 0, -1)
+	}
+	return result, true
+}
+
+//-----------------------------------------------------------------------------
+// format local time label for current weather: " (12:36, UTC+03:00)"
+func formatLocalTime(str string) string {
+	localTime, ok := parseLocalTime(str)
+	if !ok {
+		return ""
+	}
+	return localTime.Format(" (15:04, UTC-07:00)")
+}
+
+//-----------------------------------------------------------------------------
+// render data as text or JSON
+func render(forecastNow map[string]interface{}, forecastByHours []HourTemp, forecastNext []DayForecast, cfg Config) error {
+	cityFromPage, ok := forecastNow["city"]
+	if !ok || cityFromPage == "" {
+		return fmt.Errorf("City %q not found", cfg.city)
+	}
+	outWriter := getColorWriter(cfg.noColor)
+	outWriter.transliterate = cfg.transliterate
+
+	forecast := getForecastJSON(forecastNow, forecastByHours, forecastNext, cfg)
+	var forecastJSON interface{} = forecast
+	if cfg.jsonFlat {
+		forecastJSON = flatForecastJSON(forecast)
+	}
+	if cfg.jsonArray {
+		forecastJSON = []interface{}{forecastJSON}
+	}
+	jsonBytes := cfg.marshalJSON(forecastJSON)
+	if cfg.alsoJSON != "" {
+		if err := writeJSONFile(cfg.alsoJSON, append(jsonBytes, '\n'), cfg.appendJSON); err != nil {
+			return err
+		}
+	}
+
+	if cfg.brief {
+		renderBrief(outWriter, forecastNow, forecastNext, cfg)
+		return nil
+	}
+
+	if cfg.outputTemplate != nil {
+		if forecast.CurrentWeather == nil {
+			// -no-current: empty fields of current weather in template, not error of nil pointer
+			forecast.CurrentWeather = &CurrentWeather{}
+		}
+		out := strings.Builder{}
+		if err := cfg.outputTemplate.Execute(&out, forecast); err != nil {
+			return err
+		}
+		outWriter.Println(out.String())
+		return nil
+	}
+
+	if cfg.getJSON {
+		fmt.Println(string(jsonBytes))
+		return nil
+	}
+
+	if cfg.prometheus {
+		city := cfg.city
+		if city == "" {
+			city = cityFromPage.(string)
+		}
+		outWriter.Print(prometheusMetrics(forecastNow, city))
+		return nil
+	}
+
+	if cfg.noLink {
+		outWriter.Printf("%s\n", cityFromPage)
+	} else {
+		outWriter.Printf(cfg.ansiColourString("%s (<link>%s</>)\n"), cityFromPage, weatherLink(forecastNow, cfg))
+	}
+	if !cfg.noCurrent {
+		// skip lines with empty values, some layouts of page miss them
+		descNow := stringValue(forecastNow, "desc_now")
+		if descNow != "" {
+			descNow = " - " + cfg.colorDesc(descNow, "value")
+		}
+		outWriter.Printf(
+			cfg.ansiColourString("Сейчас%s: <value>%s</>%s\n"),
+			formatLocalTime(stringValue(forecastNow, "local_time")),
+			cfg.formatTempWithUnit(forecastNow["term_now"].(int)),
+			descNow,
+		)
+
+		pressure, humidity, wind := stringValue(forecastNow, "pressure"), stringValue(forecastNow, "humidity"), stringValue(forecastNow, "wind")
+		if cfg.noPressure {
+			pressure = ""
+		}
+		if cfg.noHumidity {
+			humidity = ""
+		}
+		if cfg.noWind {
+			wind = ""
+		}
+		if arrow, ok := PressureTrendArrows[stringValue(forecastNow, "pressure_trend")]; ok && pressure != "" {
+			outWriter.Printf(cfg.ansiColourString("Давление: <value>%s %s</>\n"), pressure, arrow)
+		} else if pressure != "" {
+			outWriter.Printf(cfg.ansiColourString("Давление: <value>%s</>\n"), pressure)
+		}
+		if comfort := humidityComfort(humidity); cfg.comfort && comfort != "" {
+			outWriter.Printf(cfg.ansiColourString("Влажность: <value>%s</> (%s)\n"), humidity, HumidityComfortRu[comfort])
+		} else if humidity != "" {
+			outWriter.Printf(cfg.ansiColourString("Влажность: <value>%s</>\n"), humidity)
+		}
+		if windGust := stringValue(forecastNow, "wind_gust"); windGust != "" && wind != "" {
+			outWriter.Printf(cfg.ansiColourString("Ветер: <value>%s</>, порывы: <value>%s</>\n"), wind, windGust)
+		} else if wind != "" {
+			outWriter.Printf(cfg.ansiColourString("Ветер: <value>%s</>\n"), wind)
+		}
+		if value, ok := getDewPoint(forecastNow); cfg.dewPoint && ok {
+			outWriter.Printf(cfg.ansiColourString("Точка росы: <value>%s</>\n"), cfg.formatTempWithUnit(int(math.Round(value))))
+		}
+		if clock := observedClock(stringValue(forecastNow, "observed_at")); clock != "" {
+			outWriter.Printf(cfg.ansiColourString("Наблюдалось в <value>%s</>\n"), clock)
+		}
+	}
+
+	if !cfg.noToday && len(forecastByHours) > 0 {
+		textByHour := [4]string{}
+		for _, item := range forecastByHours {
+			textByHour[0] += fmt.Sprintf("%3d ", item.Hour)
+			textByHour[2] += fmt.Sprintf("%4s", cfg.outputText(cfg.formatTemp(item.Temp)))
+			icon, exists := ICONS[item.Icon]
+			if !exists {
+				icon = " "
+			}
+			textByHour[3] += cfg.ansiColourString("<icon>" + padLeft(cfg.outputText(icon), 3) + "</> ")
+		}
+		textByHour[1] = cfg.ansiColourString("<hours>" + renderHisto(forecastByHours) + "</>")
+
+		outWriter.Println(strings.Repeat("─", len(forecastByHours)*4))
+		outWriter.Printf("%s\n%s\n%s\n%s\n",
+			cfg.ansiColourString("<hours>"+textByHour[0]+"</>"),
+			textByHour[1],
+			textByHour[2],
+			textByHour[3],
+		)
+	}
+
+	switch {
+	case len(forecastNext) > 0 && cfg.tempsOnly:
+		outWriter.Println(forecastTemps(forecastNext, cfg))
+	case len(forecastNext) > 0 && cfg.forecastFormat == "list":
+		renderForecastList(outWriter, forecastNext, cfg)
+	case len(forecastNext) > 0:
+		renderForecastTable(outWriter, forecastNext, cfg)
+	case cfg.daysLimit > 0 && !hasNextDaysOnPage(forecastNow):
+		outWriter.Println("(прогноз на несколько дней недоступен)")
+	}
+
+	if cfg.sparkline && len(forecastNext) > 0 {
+		temps := []int{}
+		for _, row := range forecastNext {
+			if !row.noTemp {
+				temps = append(temps, row.Temp)
+			}
+		}
+		outWriter.Printf(cfg.ansiColourString("Днём: <value>%s</>\n"), sparkline(temps, cfg.ascii))
+	}
+
+	if minTemp, maxTemp, ok := forecastRange(forecastNext); cfg.summary && ok {
+		outWriter.Printf(
+			cfg.ansiColourString("Днём в ближайшие %d дн.: от <value>%s</> до <value>%s</>\n"),
+			len(forecastNext), cfg.formatTempWithUnit(minTemp), cfg.formatTempWithUnit(maxTemp),
+		)
+	}
+	if avgTemp, days, ok := forecastAverage(forecastNext); cfg.average && ok {
+		outWriter.Printf(cfg.ansiColourString("Средняя днём за %d дн.: <value>%s</>\n"), days, cfg.formatTempWithUnit(avgTemp))
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// get link to the weather page: final URL after redirects (e.g. "spb" -> "saint-petersburg") or requested one
+func weatherLink(forecastNow map[string]interface{}, cfg Config) string {
+	if sourceURL := stringValue(forecastNow, "source_url"); sourceURL != "" {
+		return sourceURL
+	}
+	return cfg.baseURL + cfg.city
+}
+
+//-----------------------------------------------------------------------------
+// render current temperature and temperatures of the first day of forecast, as text or JSON
+func renderBrief(outWriter terminalWriter, forecastNow map[string]interface{}, forecastNext []DayForecast, cfg Config) {
+	brief := getBriefJSON(forecastNow, forecastNext)
+	if cfg.getJSON {
+		fmt.Println(string(cfg.marshalJSON(brief)))
+		return
+	}
+
+	outWriter.Println(brief.City)
+	if brief.FeelsNow != nil {
+		outWriter.Printf(
+			cfg.ansiColourString("Сейчас: <value>%s</>, ощущается как <value>%s</>\n"),
+			cfg.formatTempWithUnit(brief.TermNow), cfg.formatTempWithUnit(*brief.FeelsNow),
+		)
+	} else {
+		outWriter.Printf(cfg.ansiColourString("Сейчас: <value>%s</>\n"), cfg.formatTempWithUnit(brief.TermNow))
+	}
+	if len(forecastNext) > 0 && (brief.TempDay != nil || brief.TempNight != nil) {
+		// absent temperatures are skipped
+		temps := []string{}
+		if brief.TempDay != nil {
+			temps = append(temps, cfg.ansiColourString("днём <value>"+cfg.formatTempWithUnit(*brief.TempDay)+"</>"))
+		}
+		if brief.TempNight != nil {
+			temps = append(temps, cfg.ansiColourString("ночью <value>"+cfg.formatTempWithUnit(*brief.TempNight)+"</>"))
+		}
+		outWriter.Printf("%s: %s\n", cfg.formatDateHuman(forecastNext[0]), strings.Join(temps, ", "))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// get brief weather: current temperature and temperatures of the first day of forecast
+func getBriefJSON(forecastNow map[string]interface{}, forecastNext []DayForecast) BriefJSON {
+	result := BriefJSON{City: stringValue(forecastNow, "city")}
+	result.TermNow, _ = forecastNow["term_now"].(int)
+	if feelsNow, ok := forecastNow["feels_now"].(int); ok {
+		result.FeelsNow = &feelsNow
+	}
+	if len(forecastNext) > 0 {
+		today := forecastNext[0]
+		result.Date, result.TempDay, result.TempNight = today.Date, today.dayTemp(), today.nightTemp()
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get weather, with -retry-on-empty fetch the page again if it has no weather (not loaded yet),
+// with -strict return error if some of not optional fields are empty
+func getWeatherNotEmpty(ctx context.Context, cfg Config) (map[string]interface{}, []HourTemp, []DayForecast, error) {
+	forecastNow, forecastByHours, forecastNext, err := getWeather(ctx, cfg)
+	for attempt := 1; err == nil && attempt <= cfg.retryOnEmpty && isEmptyForecast(forecastNow, forecastNext); attempt++ {
+		if !cfg.retryBudget.take() {
+			cfg.logVerbose("%s: page has no weather, no retries left, -max-retries-total is exhausted", cfg.baseURL+cfg.city)
+			break
+		}
+		cfg.logVerbose("%s: page has no weather, retry %d of %d", cfg.baseURL+cfg.city, attempt, cfg.retryOnEmpty)
+		select {
+		case <-time.After(DNSRetryDelay):
+		case <-ctx.Done():
+			return forecastNow, forecastByHours, forecastNext, nil
+		}
+		forecastNow, forecastByHours, forecastNext, err = getWeather(ctx, cfg)
+	}
+
+	if empty := getEmptyCriticalSelectors(forecastNow); cfg.strict && err == nil && len(empty) > 0 {
+		err = fmt.Errorf("empty fields on the page (-strict): %s", strings.Join(empty, ", "))
+	}
+
+	return forecastNow, forecastByHours, forecastNext, err
+}
+
+//-----------------------------------------------------------------------------
+// check that page has no weather: no description, pressure and humidity now and no forecast for next days
+func isEmptyForecast(forecastNow map[string]interface{}, forecastNext []DayForecast) bool {
+	for _, name := range []string{"desc_now", "pressure", "humidity"} {
+		if stringValue(forecastNow, name) != "" {
+			return false
+		}
+	}
+	return len(forecastNext) == 0
+}
+
+//-----------------------------------------------------------------------------
+// get min and max day temperatures for next days, false if there are no days with day temperature
+func forecastRange(forecastNext []DayForecast) (int, int, bool) {
+	minTemp, maxTemp, found := 0, 0, false
+	for _, day := range forecastNext {
+		switch {
+		case day.noTemp:
+			continue
+		case !found:
+			minTemp, maxTemp, found = day.Temp, day.Temp, true
+		case day.Temp < minTemp:
+			minTemp = day.Temp
+		case day.Temp > maxTemp:
+			maxTemp = day.Temp
+		}
+	}
+
+	return minTemp, maxTemp, found
+}
+
+//-----------------------------------------------------------------------------
+// get rounded average day temperature for first AverageDays days and count of used days
+func forecastAverage(forecastNext []DayForecast) (int, int, bool) {
+	if len(forecastNext) > AverageDays {
+		forecastNext = forecastNext[:AverageDays]
+	}
+	sum, days := 0, 0
+	for _, day := range forecastNext {
+		// absent temperature is not 0
+		if !day.noTemp {
+			sum += day.Temp
+			days++
+		}
+	}
+	if days == 0 {
+		return 0, 0, false
+	}
+
+	return int(math.Round(float64(sum) / float64(days))), days, true
+}
+
+//-----------------------------------------------------------------------------
+// get exit code for current temperature out of -alert-below/-alert-above, 0 if it is in range
+func alertExitCode(termNow int, cfg Config) int {
+	switch {
+	case cfg.alertBelow != nil && termNow < *cfg.alertBelow:
+		return ExitCodeAlertBelow
+	case cfg.alertAbove != nil && termNow > *cfg.alertAbove:
+		return ExitCodeAlertAbove
+	}
+	return 0
+}
+
+//-----------------------------------------------------------------------------
+// print error and exit, with -json print error as JSON object to stdout
+func exitWithError(err error, exitCode int, cfg Config) {
+	if cfg.getJSON {
+		jsonBytes, _ := json.Marshal(ErrorJSON{Error: err.Error(), City: cfg.city})
+		fmt.Println(string(jsonBytes))
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(exitCode)
+}
+
+//-----------------------------------------------------------------------------
+// write JSON to file, truncate it or append to the end
+func writeJSONFile(fileName string, content []byte, appendToFile bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendToFile {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(fileName, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+//-----------------------------------------------------------------------------
+// render forecast for next days as table
+func renderForecastTable(outWriter terminalWriter, forecastNext []DayForecast, cfg Config) {
+	columns := cfg.columns
+	if len(columns) == 0 {
+		columns = ForecastColumnsDefault
+	}
+
+	// width of description: by content, not less than -min-width of table, not more than -max-width
+	descLength := getMaxLengthDesc(forecastNext, cfg)
+	if descLength < TodayForecastTableWidth {
+		// align with today forecast
+		descLength = TodayForecastTableWidth
+	}
+	if tableWidth := forecastTableWidth(columns, descLength, cfg); cfg.minWidth > tableWidth && hasString(columns, "desc") {
+		descLength += cfg.minWidth - tableWidth
+	}
+	if cfg.maxWidth > 0 && descLength > cfg.maxWidth {
+		descLength = cfg.maxWidth
+	}
+
+	if cfg.separator != "" {
+		renderForecastSeparated(outWriter, forecastNext, columns, cfg)
+		return
+	}
+
+	if !cfg.noHeader {
+		headers := []string{}
+		for _, column := range columns {
+			headers = append(headers, forecastHeaderCell(column, descLength, cfg))
+		}
+		width := forecastTableWidth(columns, descLength, cfg)
+		outWriter.Println(strings.Repeat("─", width))
+		outWriter.Println(cfg.ansiColourString("<header> " + strings.Join(headers, " ") + "</>"))
+		outWriter.Println(strings.Repeat("─", width))
+	}
+
+	for _, row := range forecastNext {
+		cells := []string{}
+		for _, column := range columns {
+			cells = append(cells, forecastCell(column, row, descLength, cfg))
+		}
+		outWriter.Println(" " + strings.Join(cells, " "))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// render forecast for next days as rows with values separated by -sep, without align and colors
+func renderForecastSeparated(outWriter terminalWriter, forecastNext []DayForecast, columns []string, cfg Config) {
+	if !cfg.noHeader {
+		headers := []string{}
+		for _, column := range columns {
+			headers = append(headers, strings.TrimSpace(forecastHeaderCell(column, 0, cfg)))
+		}
+		outWriter.Println(strings.Join(headers, cfg.separator))
+	}
+
+	for _, row := range forecastNext {
+		values := []string{}
+		for _, column := range columns {
+			values = append(values, forecastValue(column, row, cfg))
+		}
+		outWriter.Println(strings.Join(values, cfg.separator))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// parse comma separated list of forecast table columns, return first unknown column if any
+// empty list - default columns
+func parseColumns(list string) ([]string, string) {
+	known := map[string]bool{}
+	for _, column := range ForecastColumns {
+		known[column] = true
+	}
+
+	columns := []string{}
+	for _, column := range strings.Split(list, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		if !known[column] {
+			return nil, column
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, ""
+}
+
+//-----------------------------------------------------------------------------
+// get width of forecast table column
+func forecastColumnWidth(column string, descLength int, cfg Config) int {
+	switch column {
+	case "date":
+		return cfg.maxDateWidth()
+	case "temp":
+		return 4
+	case "desc":
+		return descLength
+	case "temp_night", "temp_feels":
+		return 8
+	}
+	return 0
+}
+
+//-----------------------------------------------------------------------------
+// get width of forecast table with columns, with space before each column
+func forecastTableWidth(columns []string, descLength int, cfg Config) int {
+	width := 1
+	for _, column := range columns {
+		width += forecastColumnWidth(column, descLength, cfg) + 1
+	}
+	return width
+}
+
+//-----------------------------------------------------------------------------
+// get header of forecast table column, aligned by width of column
+func forecastHeaderCell(column string, descLength int, cfg Config) string {
+	switch column {
+	case "date":
+		return fmt.Sprintf("%-*s", forecastColumnWidth(column, descLength, cfg), cfg.outputText("дата"))
+	case "temp":
+		return fmt.Sprintf("%4s", cfg.outputText(cfg.tempUnit()))
+	case "desc":
+		return fmt.Sprintf("%-*s", descLength, cfg.outputText("погода"))
+	case "temp_night":
+		return fmt.Sprintf("%8s", cfg.outputText(strings.TrimSpace(cfg.tempUnit()+" ночью")))
+	case "temp_feels":
+		return fmt.Sprintf("%8s", cfg.outputText(strings.TrimSpace(cfg.tempUnit()+" ощущ.")))
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// get value of forecast table column for day, aligned by width of column
+func forecastCell(column string, row DayForecast, descLength int, cfg Config) string {
+	value := forecastValue(column, row, cfg)
+	switch column {
+	case "date":
+		// pad before colorize, color codes have no width
+		value = fmt.Sprintf("%-*s", forecastColumnWidth(column, descLength, cfg), value)
+		return WeekendRe.ReplaceAllString(value, cfg.ansiColourString("<weekend>$1</>"))
+	case "desc":
+		maxLength := descLength
+		if cfg.transliterate && stringWidth(value) > descLength {
+			// "…" of truncated description is converted to "..."
+			maxLength -= 2
+		}
+		// pad before colorize, color codes have no width
+		return cfg.colorDesc(padRight(cfg.outputText(truncateString(value, maxLength)), descLength), "")
+	}
+	return fmt.Sprintf("%*s", forecastColumnWidth(column, descLength, cfg), value)
+}
+
+//-----------------------------------------------------------------------------
+// get value of forecast table column for day as is, without align and colors,
+// transliterated with -transliterate before width of cell is calculated
+func forecastValue(column string, row DayForecast, cfg Config) string {
+	value := ""
+	switch column {
+	case "date":
+		value = cfg.formatDateHuman(row)
+	case "temp":
+		value = cfg.formatTempPointer(row.dayTemp())
+	case "desc":
+		value = row.Desc
+	case "temp_night":
+		value = cfg.formatTempPointer(row.nightTemp())
+	case "temp_feels":
+		value = cfg.formatTempPointer(row.TempFeels)
+	}
+	return cfg.outputText(value)
+}
+
+//-----------------------------------------------------------------------------
+// get day/night temperatures of next days in one line: "-3°/-8° -1°/-6°"
+func forecastTemps(forecastNext []DayForecast, cfg Config) string {
+	temps := []string{}
+	for _, row := range forecastNext {
+		temps = append(temps, cfg.ansiColourString("<value>"+cfg.formatTempPointer(row.dayTemp())+"</>/"+cfg.formatTempPointer(row.nightTemp())))
+	}
+	return strings.Join(temps, " ")
+}
+
+//-----------------------------------------------------------------------------
+// render forecast for next days as list, one block for each day
+func renderForecastList(outWriter terminalWriter, forecastNext []DayForecast, cfg Config) {
+	for i, row := range forecastNext {
+		if i > 0 || !cfg.noHeader {
+			outWriter.Println(strings.Repeat("─", TodayForecastTableWidth))
+		}
+		date := WeekendRe.ReplaceAllString(cfg.formatDateHuman(row), cfg.ansiColourString("<weekend>$1</>"))
+		outWriter.Printf(cfg.ansiColourString("<header>дата:</> %s\n"), date)
+		outWriter.Printf(cfg.ansiColourString("<header>днём:</> <value>%s</>\n"), cfg.formatTempPointer(row.dayTemp()))
+		outWriter.Printf(cfg.ansiColourString("<header>ночью:</> <value>%s</>\n"), cfg.formatTempPointer(row.nightTemp()))
+		if cfg.feels && row.TempFeels != nil {
+			outWriter.Printf(cfg.ansiColourString("<header>ощущается:</> <value>%s</>\n"), cfg.formatTemp(*row.TempFeels))
+		}
+		outWriter.Printf(cfg.ansiColourString("<header>погода:</> %s\n"), cfg.colorDesc(row.Desc, ""))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// get forecast for JSON output
+func getForecastJSON(forecastNow map[string]interface{}, forecastByHours []HourTemp, forecastNext []DayForecast, cfg Config) ForecastJSON {
+	result := ForecastJSON{
+		City:      stringValue(forecastNow, "city"),
+		SourceURL: stringValue(forecastNow, "source_url"),
+		TempUnit:  "celsius",
+	}
+
+	if !cfg.noCurrent {
+		termNow, _ := forecastNow["term_now"].(int)
+		result.CurrentWeather = &CurrentWeather{
+			TermNow:       termNow,
+			DescNow:       stringValue(forecastNow, "desc_now"),
+			Pressure:      stringValue(forecastNow, "pressure"),
+			PressureTrend: stringValue(forecastNow, "pressure_trend"),
+			Humidity:      stringValue(forecastNow, "humidity"),
+			Wind:          stringValue(forecastNow, "wind"),
+			WindGust:      stringValue(forecastNow, "wind_gust"),
+		}
+		if cfg.noPressure {
+			result.Pressure, result.PressureTrend = "", ""
+		}
+		if cfg.noHumidity {
+			result.Humidity = ""
+		}
+		if cfg.noWind {
+			result.Wind, result.WindGust = "", ""
+		}
+		if cfg.comfort {
+			result.HumidityComfort = humidityComfort(result.Humidity)
+		}
+		if cfg.precision {
+			result.PressureValue = preciseValue(stringValue(forecastNow, "pressure_precise"), result.Pressure)
+			result.HumidityValue = preciseValue(stringValue(forecastNow, "humidity_precise"), result.Humidity)
+		}
+		if value, ok := getDewPoint(forecastNow); cfg.dewPoint && ok {
+			value = math.Round(value*10) / 10
+			result.DewPoint = &value
+		}
+		if localTime, ok := parseLocalTime(stringValue(forecastNow, "local_time")); ok {
+			result.LocalTime = localTime.Format(time.RFC3339)
+			result.UTCOffset = localTime.Format("-07:00")
+		}
+		result.ObservedAt = stringValue(forecastNow, "observed_at")
+		if observedTime, ok := parseObservedTime(result.ObservedAt, stringValue(forecastNow, "local_time")); ok {
+			result.ObservedTime = observedTime.Format(time.RFC3339)
+		}
+	}
+
+	if !cfg.noToday && len(forecastByHours) > 0 {
+		result.ByHours = forecastByHours
+	}
+	if len(forecastNext) > 0 {
+		result.NextDays = forecastNext
+	}
+	if minTemp, maxTemp, ok := forecastRange(forecastNext); cfg.summary && ok {
+		result.ForecastMin, result.ForecastMax = &minTemp, &maxTemp
+	}
+	if avgTemp, _, ok := forecastAverage(forecastNext); cfg.average && ok {
+		result.ForecastAvg = &avgTemp
+	}
+	if cfg.meta {
+		result.Meta = &MetaJSON{Found: stringsValue(forecastNow, "selectors_found"), Empty: stringsValue(forecastNow, "selectors_empty")}
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// marshal forecast with fields of next days as "day1_temp", "day1_desc"... keys after other keys
+func (forecast flatForecastJSON) MarshalJSON() ([]byte, error) {
+	nextDays := forecast.NextDays
+	forecast.NextDays = nil
+	data, err := json.Marshal(ForecastJSON(forecast))
+	if err != nil {
+		return nil, err
+	}
+
+	result := bytes.NewBuffer(bytes.TrimSuffix(data, []byte("}")))
+	for i, day := range nextDays {
+		dayData, err := json.Marshal(day)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(dayData))
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value := json.RawMessage{}
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+			keyData, _ := json.Marshal(fmt.Sprintf("day%d_%s", i+1, key))
+			result.WriteString(",")
+			result.Write(keyData)
+			result.WriteString(":")
+			result.Write(value)
+		}
+	}
+	result.WriteString("}")
+
+	return result.Bytes(), nil
+}
+
+//-----------------------------------------------------------------------------
+// marshal value to JSON with -json-indent and -compact-json
+func (cfg Config) marshalJSON(value interface{}) []byte {
+	jsonBytes, _ := json.Marshal(value)
+	if cfg.compactJSON {
+		if compacted, err := compactJSON(jsonBytes); err == nil {
+			jsonBytes = compacted
+		}
+	}
+	if cfg.jsonIndent {
+		indented := bytes.Buffer{}
+		if err := json.Indent(&indented, jsonBytes, "", "  "); err == nil {
+			jsonBytes = indented.Bytes()
+		}
+	}
+
+	return jsonBytes
+}
+
+//-----------------------------------------------------------------------------
+// remove keys with empty strings, nulls and empty objects from JSON, keys order is kept,
+// arrays (e.g. "next_days") and numbers are kept even if they are empty or zero
+func compactJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	result, _, err := compactJSONValue(decoder)
+	return result, err
+}
+
+//-----------------------------------------------------------------------------
+// read one JSON value from decoder, returns compacted value and is it empty
+func compactJSONValue(decoder *json.Decoder) ([]byte, bool, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		result := bytes.Buffer{}
+		result.WriteString(token.String())
+		count := 0
+		for decoder.More() {
+			key := ""
+			if token == '{' {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, false, err
+				}
+				key, _ = keyToken.(string)
+			}
+
+			value, empty, err := compactJSONValue(decoder)
+			if err != nil {
+				return nil, false, err
+			}
+			if empty && token == '{' {
+				continue
+			}
+
+			if count > 0 {
+				result.WriteByte(',')
+			}
+			if token == '{' {
+				keyBytes, _ := json.Marshal(key)
+				result.Write(keyBytes)
+				result.WriteByte(':')
+			}
+			result.Write(value)
+			count++
+		}
+		// closing delimiter
+		closeToken, err := decoder.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		result.WriteString(closeToken.(json.Delim).String())
+
+		return result.Bytes(), token == '{' && count == 0, nil
+	case string:
+		value, _ := json.Marshal(token)
+		return value, token == "", nil
+	case nil:
+		return []byte("null"), true, nil
+	default:
+		value, err := json.Marshal(token)
+		return value, false, err
+	}
+}
+
+//-----------------------------------------------------------------------------
+// render current weather in Prometheus exposition format
+func prometheusMetrics(forecastNow map[string]interface{}, city string) string {
+	label := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(city)
+	termNow := ""
+	if temp, ok := forecastNow["term_now"].(int); ok {
+		termNow = strconv.Itoa(temp)
+	}
+	metrics := []struct {
+		name  string
+		help  string
+		value string
+	}{
+		{"yandex_weather_temp_celsius", "Current temperature.", termNow},
+		{"yandex_weather_humidity_percent", "Current relative humidity.", stringValue(forecastNow, "humidity")},
+		{"yandex_weather_pressure_mmhg", "Current atmospheric pressure.", stringValue(forecastNow, "pressure")},
+		{"yandex_weather_wind_speed_mps", "Current wind speed.", stringValue(forecastNow, "wind")},
+	}
+
+	result := ""
+	for _, metric := range metrics {
+		// value is absent (e.g. in wttr.in data), not 0
+		if metric.value == "" {
+			continue
+		}
+		result += fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n%s{city=\"%s\"} %s\n",
+			metric.name, metric.help,
+			metric.name,
+			metric.name, label, strconv.FormatFloat(convertStrToFloat(metric.value), 'f', -1, 64),
+		)
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get comfort level by humidity: "dry", "comfortable" or "humid", empty if humidity unknown
+func humidityComfort(humidity string) string {
+	if humidity == "" {
+		return ""
+	}
+
+	switch value := convertStrToFloat(humidity); {
+	case value <= HumidityDryMax:
+		return "dry"
+	case value >= HumidityHumidMin:
+		return "humid"
+	default:
+		return "comfortable"
+	}
+}
+
+//-----------------------------------------------------------------------------
+// get precise values from data-value attributes in html of current weather block
+func parsePreciseValues(propsHTML string) map[string]string {
+	result := map[string]string{}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(propsHTML))
+	if err != nil {
+		return result
+	}
+
+	for name, class := range PreciseValueClasses {
+		if value := strings.TrimSpace(doc.Find("."+class).AttrOr("data-value", "")); value != "" {
+			result[name] = value
+		}
+	}
+
+	return result
+}
+
+//-----------------------------------------------------------------------------
+// get numeric value from data attribute with full precision, or from text, nil if both are empty
+func preciseValue(precise, text string) *float64 {
+	if precise == "" {
+		precise = text
+	}
+	if precise == "" {
+		return nil
+	}
+
+	value := convertStrToFloat(precise)
+	return &value
+}
+
+//-----------------------------------------------------------------------------
+// get dew point for current weather, false if humidity is unknown
+func getDewPoint(forecastNow map[string]interface{}) (float64, bool) {
+	humidity := convertStrToFloat(stringValue(forecastNow, "humidity"))
+	termNow, ok := forecastNow["term_now"].(int)
+	if !ok || humidity <= 0 {
+		return 0, false
+	}
+	return dewPoint(float64(termNow), humidity), true
+}
+
+//-----------------------------------------------------------------------------
+// one line summary of current weather
+func summaryLine(forecastNow map[string]interface{}, cfg Config) string {
+	termNow, _ := forecastNow["term_now"].(int)
+	return fmt.Sprintf("%s: %s, %s, давление %s, влажность %s, ветер %s",
+		stringValue(forecastNow, "city"),
+		cfg.formatTempWithUnit(termNow),
+		stringValue(forecastNow, "desc_now"),
+		stringValue(forecastNow, "pressure"),
+		stringValue(forecastNow, "humidity"),
+		stringValue(forecastNow, "wind"),
+	)
+}
+
+//-----------------------------------------------------------------------------
+// write summary to syslog, or to stderr if syslog is unavailable
+func logToSyslog(forecastNow map[string]interface{}, cfg Config) {
+	line := summaryLine(forecastNow, cfg)
+	if err := writeToSyslog(line); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s, write to stderr\n", err)
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+//-----------------------------------------------------------------------------
+func main() {
+	cfg := getParams()
+	if cfg.colorTest {
+		renderColorTest(getColorWriter(cfg.noColor), cfg)
+		return
+	}
+
+	// on SIGINT/SIGTERM cancel request, but let the started render finish
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, shutdownSignals...)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	if cfg.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.deadline)
+		defer cancel()
+	}
+
+	if cfg.dryRun {
+		os.Exit(runDryRun(os.Stdout, cfg))
+	}
+
+	if cfg.check {
+		os.Exit(runCheck(ctx, cfg))
+	}
+
+	if cfg.checkSelectors {
+		os.Exit(runCheckSelectors(ctx, cfg))
+	}
+
+	if cfg.citiesFile != "" {
+		os.Exit(runBatch(ctx, cfg))
+	}
+
+	if cfg.interactive && cfg.city == "" && inputIsTerminal() && !outputIsPiped() {
+		city, ok := "", false
+		if restore, err := rawTerminalInput(int(os.Stdin.Fd())); err == nil {
+			city, ok = selectCity(ctx, os.Stdin, os.Stderr, cfg)
+			restore()
+		} else {
+			// without raw terminal the name is asked by lines and city is chosen by number
+			city, ok = pickCity(ctx, os.Stdin, os.Stderr, cfg)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		cfg.city, cfg.cityName = city, city
+	}
+
+	startGetWeather := time.Now()
+	cfg.allDays = true
+	forecastNow, forecastByHours, forecastNext, err := getWeatherNotEmpty(ctx, cfg)
+	if cfg.fuzzy && errors.Is(err, errPageNotFound) {
+		for _, city := range getCityVariants(cfg.city) {
+			variantCfg := cfg
+			variantCfg.city = city
+			if variantNow, variantByHours, variantNext, variantErr := getWeatherNotEmpty(ctx, variantCfg); variantErr == nil {
+				fmt.Fprintf(os.Stderr, "city %q not found, shown %q\n", cfg.city, city)
+				cfg, forecastNow, forecastByHours, forecastNext, err = variantCfg, variantNow, variantByHours, variantNext, nil
+				break
+			}
+		}
+	}
+	// weather from fallback source is not saved to cache and snapshot, they are for Yandex page
+	fromFallback := false
+	if cfg.fallback != "" && (errors.Is(err, errUnavailable) || isNetworkError(err)) && ctx.Err() == nil {
+		fallbackCfg := cfg
+		fallbackCfg.baseURL = getBaseURLWttr()
+		if fallbackNow, fallbackByHours, fallbackNext, fallbackErr := FallbackSources[cfg.fallback](ctx, fallbackCfg); fallbackErr == nil {
+			fmt.Fprintf(os.Stderr, "%s\n(показаны данные из %s)\n", err, cfg.fallback)
+			cfg, forecastNow, forecastByHours, forecastNext, err = fallbackCfg, fallbackNow, fallbackByHours, fallbackNext, nil
+			fromFallback = true
+		} else {
+			fmt.Fprintf(os.Stderr, "fallback %s: %s\n", cfg.fallback, fallbackErr)
+		}
+	}
+	if errors.Is(err, errPageNotFound) && cfg.cityName != cfg.city && strings.ContainsAny(cfg.cityName, " \t") {
+		// slug is not found, try city name as is, with escaped spaces
+		escapedCfg := cfg
+		escapedCfg.city = url.PathEscape(cfg.cityName)
+		if escapedNow, escapedByHours, escapedNext, escapedErr := getWeatherNotEmpty(ctx, escapedCfg); escapedErr == nil {
+			cfg, forecastNow, forecastByHours, forecastNext, err = escapedCfg, escapedNow, escapedByHours, escapedNext, nil
+		}
+	}
+	var candidatesErr cityCandidatesError
+	if errors.As(err, &candidatesErr) {
+		switch {
+		case cfg.getJSON:
+			fmt.Println(string(cfg.marshalJSON(CandidatesJSON{Status: "ambiguous", City: cfg.city, Candidates: candidatesErr.candidates})))
+			os.Exit(ExitCodeAmbiguous)
+		case inputIsTerminal() && !outputIsPiped():
+			fmt.Fprintf(os.Stderr, "Найдено несколько городов %q:\n", cfg.city)
+			candidate, ok := chooseCandidate(os.Stdin, os.Stderr, candidatesErr.candidates)
+			if !ok {
+				os.Exit(ExitCodeAmbiguous)
+			}
+			cfg.city, cfg.cityName = candidate.Slug, candidate.Slug
+			forecastNow, forecastByHours, forecastNext, err = getWeatherNotEmpty(ctx, cfg)
+		default:
+			fmt.Fprintf(os.Stderr, "found several cities for %q, use slug as city name:\n", cfg.city)
+			renderCandidates(os.Stderr, candidatesErr.candidates)
+			os.Exit(ExitCodeAmbiguous)
+		}
+	}
+	cfg.logTiming("get weather (fetch and parse)", startGetWeather)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return
+		}
+		if errors.Is(err, errUnavailable) {
+			exitWithError(err, ExitCodeUnavailable, cfg)
+		}
+
+		cached, cacheErr := loadCache(cfg.city)
+		if cfg.noStale || cacheErr != nil || !isNetworkError(err) && ctx.Err() == nil {
+			exitWithError(err, 1, cfg)
+		}
+
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintf(os.Stderr, "(офлайн, показаны сохранённые данные от %s)\n", cached.Time.Format("02.01.2006 15:04"))
+		forecastNow, forecastByHours, forecastNext = cached.ForecastNow, cached.ByHours, cached.NextDays
+	} else if stringValue(forecastNow, "city") != "" && !isEmptyForecast(forecastNow, forecastNext) && cfg.htmlFile == "" && !fromFallback {
+		// page without weather doesn't replace saved one
+		// pressures are saved always, for -pressure-trend in the next runs
+		cached, _ := loadCache(cfg.city)
+		if cfg.pressureTrend && stringValue(forecastNow, "pressure_trend") == "" {
+			// the page has no trend, compare with pressure saved an hour or more ago
+			forecastNow["pressure_trend"] = pressureTrendFromCache(forecastNow, cached, time.Now())
+		}
+		pressures := addPressureReading(cached.Pressures, stringValue(forecastNow, "pressure"), time.Now())
+		if err := saveCache(cfg.city, forecastNow, forecastByHours, forecastNext, pressures); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save cache: %s\n", err)
+		}
+	}
+	// all days are saved to cache, shown ones are filtered by options
+	forecastNext = filterDays(forecastNext, cfg, time.Now())
+
+	startRender := time.Now()
+	if err := render(forecastNow, forecastByHours, forecastNext, cfg); err != nil {
+		exitWithError(err, 1, cfg)
+	}
+	cfg.logTiming("render", startRender)
+	if cfg.snapshot != nil && err == nil && !fromFallback {
+		if dir, err := saveSnapshot(cfg.snapshot, forecastNow, forecastByHours, forecastNext, cfg, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save snapshot: %s\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "snapshot is saved to %s\n", dir)
+		}
+	}
+	if cfg.toSyslog {
+		logToSyslog(forecastNow, cfg)
+	}
+	if cfg.notify {
+		termNow, _ := forecastNow["term_now"].(int)
+		message := fmt.Sprintf("%s, %s", cfg.formatTempWithUnit(termNow), stringValue(forecastNow, "desc_now"))
+		if err := sendNotification(runtime.GOOS, stringValue(forecastNow, "city"), message); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to show notification: %s\n", err)
+		}
+	}
+
+	if termNow, ok := forecastNow["term_now"].(int); ok {
+		if exitCode := alertExitCode(termNow, cfg); exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}
 }
@@ -1,6 +1,29 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/msoap/html2data"
+)
 
 func Test_clearIntegerInString(t *testing.T) {
 	testData := []struct {
@@ -53,6 +76,33 @@ func Test_convertStrToInt(t *testing.T) {
 		}, {
 			"str",
 			0,
+		}, {
+			"+5°",
+			5,
+		}, {
+			"−5°",
+			-5,
+		}, {
+			"−5 °C",
+			-5,
+		}, {
+			"5℃",
+			5,
+		}, {
+			"+5 ℃",
+			5,
+		}, {
+			"−12ᵒ",
+			-12,
+		}, {
+			"–5°",
+			-5,
+		}, {
+			"‒5",
+			-5,
+		}, {
+			"－5",
+			-5,
 		},
 	}
 
@@ -64,30 +114,1816 @@ func Test_convertStrToInt(t *testing.T) {
 	}
 }
 
-func Test_parseIcon(t *testing.T) {
+func Test_convertStrToFloat(t *testing.T) {
 	testData := []struct {
 		in  string
-		out string
+		out float64
+	}{
+		{"42", 42},
+		{"3,5 м/с, З", 3.5},
+		{"−1.5", -1.5},
+		{"745 мм рт. ст.", 745},
+		{"str", 0},
+	}
+
+	for _, item := range testData {
+		out := convertStrToFloat(item.in)
+		if out != item.out {
+			t.Errorf("expected: %#v, real: %#v", item.out, out)
+		}
+	}
+}
+
+func Test_prometheusMetrics(t *testing.T) {
+	forecastNow := map[string]interface{}{
+		"term_now": -3,
+		"humidity": "75%",
+		"pressure": "745 мм рт. ст.",
+		"wind":     "3,5 м/с, З",
+	}
+	expected := `# HELP yandex_weather_temp_celsius Current temperature.
+# TYPE yandex_weather_temp_celsius gauge
+yandex_weather_temp_celsius{city="kiev \"1\""} -3
+# HELP yandex_weather_humidity_percent Current relative humidity.
+# TYPE yandex_weather_humidity_percent gauge
+yandex_weather_humidity_percent{city="kiev \"1\""} 75
+# HELP yandex_weather_pressure_mmhg Current atmospheric pressure.
+# TYPE yandex_weather_pressure_mmhg gauge
+yandex_weather_pressure_mmhg{city="kiev \"1\""} 745
+# HELP yandex_weather_wind_speed_mps Current wind speed.
+# TYPE yandex_weather_wind_speed_mps gauge
+yandex_weather_wind_speed_mps{city="kiev \"1\""} 3.5
+`
+
+	if out := prometheusMetrics(forecastNow, `kiev "1"`); out != expected {
+		t.Errorf("expected: %s, real: %s", expected, out)
+	}
+
+	// without humidity and wind, as in wttr.in data
+	expected = `# HELP yandex_weather_temp_celsius Current temperature.
+# TYPE yandex_weather_temp_celsius gauge
+yandex_weather_temp_celsius{city="london"} 12
+# HELP yandex_weather_pressure_mmhg Current atmospheric pressure.
+# TYPE yandex_weather_pressure_mmhg gauge
+yandex_weather_pressure_mmhg{city="london"} 760
+`
+	if out := prometheusMetrics(map[string]interface{}{"term_now": 12, "pressure": "760 мм рт. ст."}, "london"); out != expected {
+		t.Errorf("expected: %s, real: %s", expected, out)
+	}
+}
+
+func Test_checkDNSError(t *testing.T) {
+	testData := []struct {
+		name      string
+		err       error
+		temporary bool
+		errText   string
 	}{
 		{
-			"",
-			"",
+			name:      "not DNS error",
+			err:       errors.New("connection refused"),
+			temporary: false,
+			errText:   "connection refused",
 		}, {
-			"icon",
-			"",
-		}, {
-			"icon icon_size_24 icon_snow",
-			"icon_snow",
+			name:      "no such host",
+			err:       &url.Error{Op: "Get", URL: "https://yandex.ru/", Err: &net.DNSError{Err: "no such host", Name: "yandex.ru", IsNotFound: true}},
+			temporary: false,
+			errText:   `host "yandex.ru" not found, check the URL: Get "https://yandex.ru/": lookup yandex.ru: no such host`,
 		}, {
-			"icon icon_size_24 icon_rain",
-			"icon_rain",
+			name:      "temporary",
+			err:       &url.Error{Op: "Get", URL: "https://yandex.ru/", Err: &net.DNSError{Err: "server misbehaving", Name: "yandex.ru", IsTemporary: true}},
+			temporary: true,
+			errText:   `temporary DNS failure for host "yandex.ru": Get "https://yandex.ru/": lookup yandex.ru: server misbehaving`,
 		},
 	}
 
 	for _, item := range testData {
-		out := parseIcon(item.in)
+		temporary, err := checkDNSError(item.err)
+		if temporary != item.temporary || err.Error() != item.errText {
+			t.Errorf("%q. expected: %v, %q, real: %v, %q", item.name, item.temporary, item.errText, temporary, err)
+		}
+	}
+}
+
+func Test_friendlyNetworkError(t *testing.T) {
+	timeoutErr := &url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: context.DeadlineExceeded}
+	refusedErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	dnsErr := &net.DNSError{Err: "no such host", Name: "yandex.ru", IsNotFound: true}
+	tlsErr := &url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: errors.New("x509: certificate signed by unknown authority")}
+	otherErr := errors.New("some error")
+
+	testData := []struct {
+		err     error
+		retries int
+		out     string
+	}{
+		{timeoutErr, 0, "request timed out: " + timeoutErr.Error() + " (try -retries N to repeat the request)"},
+		{timeoutErr, 2, "request timed out: " + timeoutErr.Error()},
+		{refusedErr, 0, "network error: " + refusedErr.Error() + " (try -retries N to repeat the request)"},
+		{dnsErr, 0, dnsErr.Error()},
+		{tlsErr, 0, tlsErr.Error()},
+		{otherErr, 0, otherErr.Error()},
+	}
+
+	for i, item := range testData {
+		err := friendlyNetworkError(item.err, Config{retries: item.retries})
+		if err.Error() != item.out || !errors.Is(err, item.err) {
+			t.Errorf("%d. expected: %q, real: %q", i, item.out, err)
+		}
+	}
+}
+
+func Test_isNetworkError(t *testing.T) {
+	testData := []struct {
+		err error
+		out bool
+	}{
+		{&url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: context.DeadlineExceeded}, true},
+		{&url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, true},
+		{&net.DNSError{Err: "no such host", Name: "yandex.ru", IsNotFound: true}, true},
+		{&url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: errors.New("x509: certificate signed by unknown authority")}, false},
+		{&url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: &net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")}}, false},
+		{&url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: &net.OpError{Op: "local error", Err: errors.New("tls: bad record MAC")}}, false},
+		{&url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: x509.UnknownAuthorityError{}}, false},
+		{&url.Error{Op: "Get", URL: "https://yandex.ru/pogoda/", Err: &net.OpError{Op: "read", Err: x509.HostnameError{Host: "yandex.ru", Certificate: &x509.Certificate{}}}}, false},
+		{errors.New("some error"), false},
+	}
+
+	for i, item := range testData {
+		if out := isNetworkError(item.err); out != item.out {
+			t.Errorf("%d. %v: expected: %v, real: %v", i, item.err, item.out, out)
+		}
+	}
+}
+
+func Test_parsePressureTrend(t *testing.T) {
+	testData := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"icon icon_size_12", ""},
+		{"icon icon_pressure-up", "rising"},
+		{"icon icon_pressure_down", "falling"},
+		{"icon icon_pressure-stable", "steady"},
+	}
+
+	for _, item := range testData {
+		if out := parsePressureTrend(item.in); out != item.out {
+			t.Errorf("expected: %#v, real: %#v", item.out, out)
+		}
+	}
+}
+
+func Test_formatLocalTime(t *testing.T) {
+	testData := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"12:36", ""},
+		{"2021-06-28T12:36:00+03:00", " (12:36, UTC+03:00)"},
+		{"2021-06-28 12:36+0300", " (12:36, UTC+03:00)"},
+		{"2021-06-28 07:05-0500", " (07:05, UTC-05:00)"},
+	}
+
+	for _, item := range testData {
+		if out := formatLocalTime(item.in); out != item.out {
+			t.Errorf("expected: %#v, real: %#v", item.out, out)
+		}
+	}
+}
+
+func Test_parseObservedTime(t *testing.T) {
+	testData := []struct {
+		observed, localTime string
+		clock, out          string
+	}{
+		{"", "2021-06-28 12:36+0300", "", ""},
+		{"Наблюдалось в 12:30", "", "12:30", ""},
+		{"Наблюдалось в 12:30", "2021-06-28 12:36+0300", "12:30", "2021-06-28T12:30:00+03:00"},
+		{"9:00", "2021-06-28 12:36-0500", "9:00", "2021-06-28T09:00:00-05:00"},
+		{"Наблюдалось в 23:50", "2021-06-28 00:10+0300", "23:50", "2021-06-27T23:50:00+03:00"},
+	}
+
+	for _, item := range testData {
+		if clock := observedClock(item.observed); clock != item.clock {
+			t.Errorf("expected: %#v, real: %#v", item.clock, clock)
+		}
+		out := ""
+		if observedTime, ok := parseObservedTime(item.observed, item.localTime); ok {
+			out = observedTime.Format(time.RFC3339)
+		}
+		if out != item.out {
+			t.Errorf("expected: %#v, real: %#v", item.out, out)
+		}
+	}
+}
+
+func Test_humidityComfort(t *testing.T) {
+	testData := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"25%", "dry"},
+		{"30%", "dry"},
+		{"45%", "comfortable"},
+		{"60%", "humid"},
+		{"98%", "humid"},
+	}
+
+	for _, item := range testData {
+		out := humidityComfort(item.in)
 		if out != item.out {
 			t.Errorf("expected: %#v, real: %#v", item.out, out)
 		}
 	}
 }
+
+func Test_parseAliases(t *testing.T) {
+	content := `
+# my cities
+home = 213
+work=kiev
+ london  =  london
+broken line
+empty =
+`
+	expected := map[string]string{
+		"home":   "213",
+		"work":   "kiev",
+		"london": "london",
+	}
+
+	if out := parseAliases(content); !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected: %#v, real: %#v", expected, out)
+	}
+}
+
+func Test_parseCitiesFile(t *testing.T) {
+	content := `
+# offices
+moscow
+  213
+
+kiev
+`
+	expected := []string{"moscow", "213", "kiev"}
+
+	if out := parseCitiesFile(content); !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected: %#v, real: %#v", expected, out)
+	}
+	if out := parseCitiesFile(""); !reflect.DeepEqual(out, []string{}) {
+		t.Errorf("expected empty list, real: %#v", out)
+	}
+}
+
+func Test_descCondition(t *testing.T) {
+	testData := map[string]string{
+		"Снег":               "icon_snow",
+		"небольшой снег":     "icon_snow",
+		"Метель":             "icon_snow",
+		"дождь":              "icon_rain",
+		"Ливень":             "icon_rain",
+		"небольшой ливневый": "icon_rain",
+		"Облачно с прояснениями": "",
+		"": "",
+	}
+
+	for desc, expected := range testData {
+		if out := descCondition(desc); out != expected {
+			t.Errorf("descCondition(%q): expected: %#v, real: %#v", desc, expected, out)
+		}
+	}
+}
+
+func Test_skipDays(t *testing.T) {
+	days := []DayForecast{{Date: "2021-06-29"}, {Date: "2021-06-30"}, {Date: "2021-07-01"}}
+	testData := []struct {
+		skip int
+		out  []DayForecast
+	}{
+		{0, days},
+		{2, []DayForecast{{Date: "2021-07-01"}}},
+		{3, []DayForecast{}},
+		{10, []DayForecast{}},
+	}
+
+	for _, item := range testData {
+		out := skipDays(days, item.skip)
+		if !reflect.DeepEqual(out, item.out) {
+			t.Errorf("expected: %#v, real: %#v", item.out, out)
+		}
+	}
+}
+
+func Test_isDayNightInverted(t *testing.T) {
+	testData := []struct {
+		name string
+		in   []DayForecast
+		out  bool
+	}{
+		{"empty", []DayForecast{}, false},
+		{"normal", []DayForecast{{Temp: 5, TempNight: 1}, {Temp: 3, TempNight: 4}, {Temp: 2, TempNight: -1}}, false},
+		{"inverted", []DayForecast{{Temp: 1, TempNight: 5}, {Temp: 3, TempNight: 4}, {Temp: 2, TempNight: -1}}, true},
+		{"half", []DayForecast{{Temp: 1, TempNight: 5}, {Temp: 3, TempNight: 1}}, false},
+		{"missing night", []DayForecast{{Temp: 1, TempNight: 5}, {Temp: -3, noTempNight: true}, {Temp: -2, noTempNight: true}}, true},
+		{"missing all", []DayForecast{{Temp: -3, noTempNight: true}, {TempNight: 2, noTemp: true}}, false},
+	}
+
+	for _, item := range testData {
+		if out := isDayNightInverted(item.in); out != item.out {
+			t.Errorf("%q. expected: %v, real: %v", item.name, item.out, out)
+		}
+	}
+
+	days := []DayForecast{{Temp: 1, TempNight: 5}, {Temp: 3, TempNight: 4}, {Temp: 2, noTempNight: true}}
+	swapDayNight(days)
+	if expected := []DayForecast{{Temp: 5, TempNight: 1}, {Temp: 4, TempNight: 3}, {TempNight: 2, noTemp: true}}; !reflect.DeepEqual(days, expected) {
+		t.Errorf("swapDayNight: expected: %#v, real: %#v", expected, days)
+	}
+}
+
+func Test_getForecastJSON(t *testing.T) {
+	forecastNow := map[string]interface{}{
+		"city":       "Погода в Москве",
+		"source_url": "https://yandex.ru/pogoda/moscow",
+		"term_now":   -3,
+		"desc_now":   "Облачно",
+		"wind":       "3 м/с, З",
+		"humidity":   "75%",
+		"pressure":   "745 мм рт. ст.",
+	}
+	forecastByHours := []HourTemp{{Hour: 17, Temp: -3, Icon: "icon_snow"}}
+	forecastNext := []DayForecast{{DateHuman: "29.06 (вт)", Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14}}
+
+	testData := []struct {
+		name string
+		cfg  Config
+		out  string
+	}{
+		{
+			name: "full",
+			cfg:  Config{comfort: true},
+			out: `{"city":"Погода в Москве","source_url":"https://yandex.ru/pogoda/moscow",` +
+				`"term_now":-3,"desc_now":"Облачно","pressure":"745 мм рт. ст.","humidity":"75%","humidity_comfort":"humid","wind":"3 м/с, З",` +
+				`"temp_unit":"celsius","by_hours":[{"hour":17,"temp":-3,"icon":"icon_snow"}],` +
+				`"next_days":[{"date":"2021-06-29","desc":"облачно","temp":24,"temp_night":14,"temp_feels":null}]}`,
+		}, {
+			name: "without current and today",
+			cfg:  Config{noCurrent: true, noToday: true},
+			out: `{"city":"Погода в Москве","source_url":"https://yandex.ru/pogoda/moscow","temp_unit":"celsius",` +
+				`"next_days":[{"date":"2021-06-29","desc":"облачно","temp":24,"temp_night":14,"temp_feels":null}]}`,
+		},
+	}
+
+	for _, item := range testData {
+		out, err := json.Marshal(getForecastJSON(forecastNow, forecastByHours, forecastNext, item.cfg))
+		if err != nil || string(out) != item.out {
+			t.Errorf("%q. expected: %s, real: %s (%v)", item.name, item.out, out, err)
+		}
+	}
+
+	partialNow := map[string]interface{}{"city": "Погода в Москве", "term_now": -3, "desc_now": "Облачно", "wind": "", "humidity": "", "pressure": ""}
+	expected := `{"city":"Погода в Москве","source_url":"","term_now":-3,"desc_now":"Облачно","temp_unit":"celsius"}`
+	if out, err := json.Marshal(getForecastJSON(partialNow, nil, nil, Config{noToday: true})); err != nil || string(out) != expected {
+		t.Errorf("partial. expected: %s, real: %s (%v)", expected, out, err)
+	}
+}
+
+func Test_forecastRange(t *testing.T) {
+	testData := []struct {
+		in       []DayForecast
+		min, max int
+		ok       bool
+	}{
+		{nil, 0, 0, false},
+		{[]DayForecast{{Temp: 3}}, 3, 3, true},
+		{[]DayForecast{{Temp: -2}, {Temp: 4, TempNight: -10}, {Temp: -8}, {Temp: 1}}, -8, 4, true},
+		{[]DayForecast{{noTemp: true}, {Temp: 3}, {Temp: 5}}, 3, 5, true},
+		{[]DayForecast{{noTemp: true, TempNight: 2}}, 0, 0, false},
+	}
+
+	for i, item := range testData {
+		if min, max, ok := forecastRange(item.in); min != item.min || max != item.max || ok != item.ok {
+			t.Errorf("%d. expected: %d, %d, %v, real: %d, %d, %v", i, item.min, item.max, item.ok, min, max, ok)
+		}
+	}
+}
+
+func Test_filterDays(t *testing.T) {
+	now := time.Date(2030, 1, 3, 12, 0, 0, 0, time.UTC)
+	// saved to cache a few days ago
+	forecastNext := []DayForecast{{Date: "2030-01-02"}, {Date: "2030-01-03"}, {Date: "2030-01-04"}, {Date: "2030-01-05"}, {Date: "2030-01-06"}, {Date: "2030-01-07"}}
+
+	testData := []struct {
+		name string
+		cfg  Config
+		out  []DayForecast
+	}{
+		{"days", Config{daysLimit: 2}, []DayForecast{{Date: "2030-01-04"}, {Date: "2030-01-05"}}},
+		{"skip", Config{daysLimit: 2, skipDays: 1}, []DayForecast{{Date: "2030-01-05"}, {Date: "2030-01-06"}}},
+		{"weekends", Config{daysLimit: 10, weekends: true}, []DayForecast{{Date: "2030-01-05"}, {Date: "2030-01-06"}}},
+		{"without days", Config{}, []DayForecast{}},
+	}
+
+	for _, item := range testData {
+		if out := filterDays(forecastNext, item.cfg, now); !reflect.DeepEqual(out, item.out) {
+			t.Errorf("%q. expected: %#v, real: %#v", item.name, item.out, out)
+		}
+	}
+}
+
+func Test_weekendDays(t *testing.T) {
+	forecastNext := []DayForecast{{Date: "2030-01-04"}, {Date: "2030-01-05"}, {Date: "2030-01-06"}, {Date: ""}, {Date: "2030-01-07"}, {Date: "2030-01-12"}}
+	expected := []DayForecast{{Date: "2030-01-05"}, {Date: "2030-01-06"}, {Date: "2030-01-12"}}
+	if out := weekendDays(forecastNext); !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected: %#v, real: %#v", expected, out)
+	}
+	if out := weekendDays(forecastNext[:1]); !reflect.DeepEqual(out, []DayForecast{}) {
+		t.Errorf("expected empty forecast, real: %#v", out)
+	}
+}
+
+func Test_forecastAverage(t *testing.T) {
+	testData := []struct {
+		in        []DayForecast
+		avg, days int
+		ok        bool
+	}{
+		{nil, 0, 0, false},
+		{[]DayForecast{{Temp: 3}}, 3, 1, true},
+		{[]DayForecast{{Temp: -2}, {Temp: -3}}, -3, 2, true},
+		{[]DayForecast{{Temp: -2}, {Temp: 4, TempNight: -10}, {Temp: -8}, {Temp: 1}}, -1, 4, true},
+		{[]DayForecast{{Temp: 1}, {Temp: 1}, {Temp: 1}, {Temp: 1}, {Temp: 1}, {Temp: 1}, {Temp: 1}, {Temp: 30}}, 1, 7, true},
+		{[]DayForecast{{Temp: 6}, {noTemp: true}, {Temp: 8}}, 7, 2, true},
+		{[]DayForecast{{noTemp: true}}, 0, 0, false},
+	}
+
+	for i, item := range testData {
+		if avg, days, ok := forecastAverage(item.in); avg != item.avg || days != item.days || ok != item.ok {
+			t.Errorf("%d. expected: %d, %d, %v, real: %d, %d, %v", i, item.avg, item.days, item.ok, avg, days, ok)
+		}
+	}
+}
+
+func Test_forecastTemps(t *testing.T) {
+	forecastNext := []DayForecast{{Temp: -3, TempNight: -8}, {Temp: 2, TempNight: -1}}
+	testData := []struct {
+		cfg Config
+		out string
+	}{
+		{Config{noColor: true, celsiusSymbol: "°C"}, "-3°/-8° 2°/-1°"},
+		{Config{noColor: true, celsiusSymbol: "none", unicodeMinus: true}, "−3/−8 2/−1"},
+		{Config{noColor: true, celsiusSymbol: "C"}, "-3C/-8C 2C/-1C"},
+	}
+
+	for i, item := range testData {
+		if out := forecastTemps(forecastNext, item.cfg); out != item.out {
+			t.Errorf("%d. expected: %#v, real: %#v", i, item.out, out)
+		}
+	}
+	if out := forecastTemps([]DayForecast{{Temp: -3, noTempNight: true}}, Config{noColor: true, celsiusSymbol: "°C"}); out != "-3°/" {
+		t.Errorf("expected: %#v, real: %#v", "-3°/", out)
+	}
+	if out := forecastTemps(nil, Config{}); out != "" {
+		t.Errorf("expected empty line, real: %#v", out)
+	}
+}
+
+func Test_getCityCandidates(t *testing.T) {
+	testData := []struct {
+		name string
+		html string
+		out  []CityCandidate
+	}{
+		{
+			name: "search page",
+			html: `<ul class="place-list">
+				<li class="place-list__item"><a class="place-list__item-name" href="/pogoda/troitsk?via=srp">Троицк</a><span class="place-list__item-region-name">Москва</span></li>
+				<li class="place-list__item"><a class="place-list__item-name" href="/pogoda/troitsk-chelyabinsk">Троицк</a><span class="place-list__item-region-name">Челябинская область</span></li>
+			</ul>`,
+			out: []CityCandidate{{Name: "Троицк", Region: "Москва", Slug: "troitsk"}, {Name: "Троицк", Region: "Челябинская область", Slug: "troitsk-chelyabinsk"}},
+		},
+		{
+			name: "without regions",
+			html: `<ul class="place-list">
+				<li class="place-list__item"><a class="place-list__item-name" href="/pogoda/troitsk">Троицк</a><span class="place-list__item-region-name">Москва</span></li>
+				<li class="place-list__item"><a class="place-list__item-name" href="/pogoda/troitsk-chelyabinsk">Троицк</a></li>
+			</ul>`,
+			out: []CityCandidate{{Name: "Троицк", Slug: "troitsk"}, {Name: "Троицк", Slug: "troitsk-chelyabinsk"}},
+		},
+		{
+			name: "weather page",
+			html: `<div class="fact"><div class="fact__temp">+20</div></div>
+				<ul class="place-list"><li class="place-list__item"><a class="place-list__item-name" href="/pogoda/troitsk">Троицк</a></li></ul>`,
+			out: nil,
+		},
+		{
+			name: "without list",
+			html: `<div class="fact"></div>`,
+			out:  []CityCandidate{},
+		},
+	}
+
+	for _, item := range testData {
+		if out := getCityCandidates(html2data.FromReader(strings.NewReader(item.html))); !reflect.DeepEqual(out, item.out) {
+			t.Errorf("%s: expected: %#v, real: %#v", item.name, item.out, out)
+		}
+	}
+}
+
+func Test_chooseCandidate(t *testing.T) {
+	candidates := []CityCandidate{{Name: "Троицк", Region: "Москва", Slug: "troitsk"}, {Name: "Троицк", Slug: "troitsk-chelyabinsk"}}
+	testData := []struct {
+		in  string
+		out string
+		ok  bool
+	}{
+		{"2\n", "troitsk-chelyabinsk", true},
+		{" 1 ", "troitsk", true},
+		{"3\n", "", false},
+		{"troitsk\n", "", false},
+		{"", "", false},
+	}
+
+	for i, item := range testData {
+		output := bytes.Buffer{}
+		if out, ok := chooseCandidate(strings.NewReader(item.in), &output, candidates); out.Slug != item.out || ok != item.ok {
+			t.Errorf("%d. expected: %#v, %v, real: %#v, %v", i, item.out, item.ok, out.Slug, ok)
+		}
+		if expected := " 1. Троицк, Москва (troitsk)\n 2. Троицк (troitsk-chelyabinsk)\nНомер города (1-2): "; output.String() != expected {
+			t.Errorf("%d. expected: %#v, real: %#v", i, expected, output.String())
+		}
+	}
+}
+
+// server of Yandex search for city pickers: several cities, redirect to the only city, not found city
+func newCitySearchServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Query().Get("request") {
+		case "троицк":
+			fmt.Fprint(w, `<ul class="place-list">
+				<li class="place-list__item"><a class="place-list__item-name" href="/pogoda/troitsk">Троицк</a></li>
+				<li class="place-list__item"><a class="place-list__item-name" href="/pogoda/troitsk-chelyabinsk">Троицк</a></li>
+			</ul>`)
+		case "казань":
+			http.Redirect(w, r, "/kazan", http.StatusFound)
+		case "":
+			fmt.Fprint(w, `<div class="fact"><div class="fact__temp">+20</div></div>`)
+		default:
+			fmt.Fprint(w, `<ul class="place-list"></ul>`)
+		}
+	}))
+}
+
+func Test_pickCity(t *testing.T) {
+	server := newCitySearchServer()
+	defer server.Close()
+
+	testData := []struct {
+		in   string
+		city string
+		ok   bool
+	}{
+		{"троицк\n2\n", "troitsk-chelyabinsk", true},
+		{"казань\n", "kazan", true},
+		{"абвгд\nтроицк\n1\n", "troitsk", true},
+		{"\n", "", true},
+		{"абвгд\n", "", false},
+		{"", "", false},
+	}
+
+	cfg := Config{baseURL: server.URL + "/"}
+	for i, item := range testData {
+		if city, ok := pickCity(context.Background(), strings.NewReader(item.in), ioutil.Discard, cfg); city != item.city || ok != item.ok {
+			t.Errorf("%d. expected: %#v, %v, real: %#v, %v", i, item.city, item.ok, city, ok)
+		}
+	}
+}
+
+// keysReader - keys pressed one by one on terminal
+type keysReader []string
+
+func (keys *keysReader) Read(p []byte) (int, error) {
+	if len(*keys) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, (*keys)[0])
+	*keys = (*keys)[1:]
+	return n, nil
+}
+
+func Test_selectCity(t *testing.T) {
+	server := newCitySearchServer()
+	defer server.Close()
+
+	testData := []struct {
+		keys keysReader
+		city string
+		ok   bool
+	}{
+		{keysReader{"т", "р", "о", "и", "ц", "к", "\x1b[B", "\r"}, "troitsk-chelyabinsk", true},
+		{keysReader{"троицк", "\x1b[B", "\x1b[B", "\x1bOA", "\r"}, "troitsk", true},
+		{keysReader{"троицкк", "\x7f", "\x0e", "\r"}, "troitsk-chelyabinsk", true},
+		{keysReader{"казань", "\r"}, "kazan", true},
+		{keysReader{"\r"}, "", true},
+		// Enter without found cities is ignored
+		{keysReader{"абвгд", "\r", "\x03"}, "", false},
+		{keysReader{"т", "\r", "\x04"}, "", false},
+		{keysReader{}, "", false},
+	}
+
+	cfg := Config{baseURL: server.URL + "/", noColor: true}
+	for i, item := range testData {
+		out := bytes.Buffer{}
+		if city, ok := selectCity(context.Background(), &item.keys, &out, cfg); city != item.city || ok != item.ok {
+			t.Errorf("%d. expected: %#v, %v, real: %#v, %v", i, item.city, item.ok, city, ok)
+		}
+	}
+
+	out := bytes.Buffer{}
+	selectCity(context.Background(), &keysReader{"троицк", "\x1b[B", "\r"}, &out, cfg)
+	if !strings.Contains(out.String(), "\n  Троицк (troitsk)\n> Троицк (troitsk-chelyabinsk)\x1b[2A\r") ||
+		!strings.HasSuffix(out.String(), PickerPrompt+"Троицк (troitsk-chelyabinsk)\n") {
+		t.Errorf("unexpected output of picker: %q", out.String())
+	}
+}
+
+func Test_isMaintenancePage(t *testing.T) {
+	testData := []struct {
+		name string
+		html string
+		out  bool
+	}{
+		{
+			name: "technical works",
+			html: `<html><head><title>Яндекс</title></head><body><div class="content">` +
+				`<h1>Ведутся технические работы</h1><p>Сервис временно недоступен, попробуйте зайти позже.</p></div></body></html>`,
+			out: true,
+		}, {
+			name: "weather page with a note about works",
+			html: `<html><body><div class="fact"><div class="fact__temp">-3</div></div>` +
+				`<div class="news">На дорогах технические работы</div></body></html>`,
+			out: false,
+		}, {
+			name: "weather page",
+			html: `<html><body><div class="fact"><div class="fact__temp">-3</div></div></body></html>`,
+			out:  false,
+		},
+	}
+
+	for _, item := range testData {
+		if out := isMaintenancePage(html2data.FromReader(strings.NewReader(item.html))); out != item.out {
+			t.Errorf("%q. expected: %#v, real: %#v", item.name, item.out, out)
+		}
+	}
+}
+
+func Test_parseColumns(t *testing.T) {
+	testData := []struct {
+		in      string
+		out     []string
+		unknown string
+	}{
+		{"date,temp,desc,temp_night", []string{"date", "temp", "desc", "temp_night"}, ""},
+		{"temp_night, date", []string{"temp_night", "date"}, ""},
+		{"date,precip", nil, "precip"},
+		{"date,,temp", []string{"date", "temp"}, ""},
+		{"", []string{}, ""},
+	}
+
+	for _, item := range testData {
+		if out, unknown := parseColumns(item.in); !reflect.DeepEqual(out, item.out) || unknown != item.unknown {
+			t.Errorf("%q. expected: %#v, %q, real: %#v, %q", item.in, item.out, item.unknown, out, unknown)
+		}
+	}
+}
+
+func Test_forecastValue(t *testing.T) {
+	feels := -7
+	row := DayForecast{DateHuman: "02.01 (сб)", Temp: -2, TempNight: -6, TempFeels: &feels, Desc: "небольшой снег"}
+	cfg := Config{celsiusSymbol: "°C"}
+
+	testData := map[string]string{
+		"date":       "02.01 (сб)",
+		"temp":       "-2°",
+		"desc":       "небольшой снег",
+		"temp_night": "-6°",
+		"temp_feels": "-7°",
+	}
+
+	for column, expected := range testData {
+		if out := forecastValue(column, row, cfg); out != expected {
+			t.Errorf("%q. expected: %#v, real: %#v", column, expected, out)
+		}
+	}
+
+	row.TempFeels, row.noTemp, row.noTempNight = nil, true, true
+	for _, column := range []string{"temp", "temp_night", "temp_feels"} {
+		if out := forecastValue(column, row, cfg); out != "" {
+			t.Errorf("%q. expected: %#v, real: %#v", column, "", out)
+		}
+	}
+}
+
+func Test_forecastCellTransliterate(t *testing.T) {
+	row := DayForecast{DateHuman: "02.01 (сб)", Temp: -2, TempNight: -6, Desc: "небольшой снег"}
+	cfg := Config{celsiusSymbol: "°C", noColor: true, transliterate: true}
+
+	testData := []struct {
+		column     string
+		descLength int
+		out        string
+	}{
+		{"date", 0, "02.01 (sb) "},
+		{"temp", 0, "  -2"},
+		{"desc", 20, "nebolshoy sneg      "},
+		{"desc", 10, "nebolsh..."},
+		{"temp_night", 0, "      -6"},
+	}
+
+	for i, item := range testData {
+		if out := forecastCell(item.column, row, item.descLength, cfg); out != item.out {
+			t.Errorf("%d. expected: %#v, real: %#v", i, item.out, out)
+		}
+	}
+	if out := forecastHeaderCell("temp_night", 0, cfg); out != "C nochyu" {
+		t.Errorf("expected: %#v, real: %#v", "C nochyu", out)
+	}
+	if out := getMaxLengthDesc([]DayForecast{row}, cfg); out != 14 {
+		t.Errorf("expected: %#v, real: %#v", 14, out)
+	}
+}
+
+func Test_isAcceptLanguage(t *testing.T) {
+	testData := map[string]bool{
+		"en":                      true,
+		"en-US":                   true,
+		"uk, ru;q=0.8":            true,
+		"ru-RU,ru;q=0.9,en;q=0.1": true,
+		"*":                       true,
+		"":                        false,
+		"en;q=2":                  false,
+		"english language":        false,
+		"en,":                     false,
+	}
+
+	for in, out := range testData {
+		if real := isAcceptLanguage(in); real != out {
+			t.Errorf("%q. expected: %#v, real: %#v", in, out, real)
+		}
+	}
+}
+
+func Test_forecastTableWidth(t *testing.T) {
+	testData := []struct {
+		columns    []string
+		descLength int
+		cfg        Config
+		out        int
+	}{
+		{ForecastColumnsDefault, 29, Config{}, 56},
+		{ForecastColumnsDefault, 43, Config{}, 70},
+		{[]string{"date", "temp"}, 29, Config{}, 17},
+		{[]string{"date", "desc"}, 20, Config{weekdayStyle: "long"}, 42},
+		{ForecastColumnsDefault, 0, Config{}, 27},
+		{[]string{"date", "desc", "temp_feels"}, 0, Config{weekdayStyle: "long"}, 31},
+		{[]string{"date", "desc"}, 0, Config{weekdayStyle: "long", transliterate: true}, 21},
+	}
+
+	for i, item := range testData {
+		if out := forecastTableWidth(item.columns, item.descLength, item.cfg); out != item.out {
+			t.Errorf("%d. expected: %#v, real: %#v", i, item.out, out)
+		}
+	}
+}
+
+func Test_isHTMLContentType(t *testing.T) {
+	testData := map[string]bool{
+		"":                           true,
+		"text/html":                  true,
+		"text/html; charset=utf-8":   true,
+		"Text/HTML; charset=UTF-8":   true,
+		"application/xhtml+xml":      true,
+		"application/json":           false,
+		"application/octet-stream":   false,
+		"image/png":                  false,
+		"text/html; charset=\"utf-8": true,
+		"/":                          false,
+	}
+
+	for contentType, expected := range testData {
+		if out := isHTMLContentType(contentType); out != expected {
+			t.Errorf("%q. expected: %#v, real: %#v", contentType, expected, out)
+		}
+	}
+}
+
+func Test_preciseValue(t *testing.T) {
+	html := `<html><body><div class="fact"><div class="fact__props">` +
+		`<div class="fact__pressure" data-value="745.62">Давление: 745 мм рт. ст.</div>` +
+		`<div class="fact__humidity">Влажность: 75%</div>` +
+		`</div></div></body></html>`
+	propsHTML, err := html2data.FromReader(strings.NewReader(html)).GetDataSingle(SelectorPreciseValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := parsePreciseValues(propsHTML)
+	if expected := map[string]string{"pressure_precise": "745.62"}; !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected: %#v, real: %#v", expected, data)
+	}
+
+	testData := []struct {
+		name          string
+		precise, text string
+		expected      float64
+	}{
+		{"pressure from data attribute", data["pressure_precise"], "745 мм рт. ст.", 745.62},
+		{"humidity from text", data["humidity_precise"], "75%", 75},
+	}
+	for _, item := range testData {
+		if out := preciseValue(item.precise, item.text); out == nil || *out != item.expected {
+			t.Errorf("%q. expected: %v, real: %v", item.name, item.expected, out)
+		}
+	}
+
+	if out := preciseValue("", ""); out != nil {
+		t.Errorf("expected nil for empty values, real: %v", *out)
+	}
+}
+
+func Test_alertExitCode(t *testing.T) {
+	zero, ten := 0, 10
+	testData := []struct {
+		termNow int
+		cfg     Config
+		out     int
+	}{
+		{-5, Config{}, 0},
+		{-5, Config{alertBelow: &zero}, ExitCodeAlertBelow},
+		{0, Config{alertBelow: &zero}, 0},
+		{11, Config{alertBelow: &zero, alertAbove: &ten}, ExitCodeAlertAbove},
+		{10, Config{alertBelow: &zero, alertAbove: &ten}, 0},
+		{5, Config{alertAbove: &zero}, ExitCodeAlertAbove},
+	}
+
+	for i, item := range testData {
+		if out := alertExitCode(item.termNow, item.cfg); out != item.out {
+			t.Errorf("%d. expected: %d, real: %d", i, item.out, out)
+		}
+	}
+}
+
+func Test_getNotifyCommand(t *testing.T) {
+	testData := []struct {
+		goos string
+		name string
+		args []string
+	}{
+		{"linux", "notify-send", []string{"--", "Погода в Москве", `-3 °C, "снег"`}},
+		{"darwin", "osascript", []string{"-e", `display notification "-3 °C, \"снег\"" with title "Погода в Москве"`}},
+		{"plan9", "", nil},
+	}
+
+	for _, item := range testData {
+		name, args := getNotifyCommand(item.goos, "Погода в Москве", `-3 °C, "снег"`)
+		if name != item.name || !reflect.DeepEqual(args, item.args) {
+			t.Errorf("%s. expected: %s %#v, real: %s %#v", item.goos, item.name, item.args, name, args)
+		}
+	}
+
+	if name, args := getNotifyCommand("windows", "it's", "-3 °C"); name != "powershell" || !strings.Contains(args[2], "'it''s', '-3 °C'") {
+		t.Errorf("windows. unexpected command: %s %#v", name, args)
+	}
+}
+
+func Test_getCityVariants(t *testing.T) {
+	testData := []struct {
+		in  string
+		out []string
+	}{
+		{"moscow", []string{}},
+		{"йошкар-ола", []string{"yoshkar-ola"}},
+		{"new york", []string{"new-york"}},
+		{"нижний новгород", []string{"нижний-новгород", "nizhniy-novgorod"}},
+	}
+
+	for _, item := range testData {
+		if out := getCityVariants(item.in); !reflect.DeepEqual(out, item.out) {
+			t.Errorf("%q. expected: %#v, real: %#v", item.in, item.out, out)
+		}
+	}
+}
+
+func Test_isRegionPage(t *testing.T) {
+	testData := []struct {
+		in  string
+		out bool
+	}{
+		{"https://yandex.ru/pogoda/moscow", false},
+		{"https://yandex.ru/pogoda/region/225", true},
+		{"https://yandex.ru/pogoda/region/225?via=reg", true},
+		{"https://yandex.ru/pogoda/region", true},
+		{"https://yandex.ru/pogoda/regional-city", false},
+		{"http://localhost:8080/get?url=https://yandex.ru/pogoda/region/225", false},
+	}
+
+	for _, item := range testData {
+		if out := isRegionPage(item.in); out != item.out {
+			t.Errorf("%q. expected: %v, real: %v", item.in, item.out, out)
+		}
+	}
+}
+
+func Test_parseIcon(t *testing.T) {
+	testData := []struct {
+		in  string
+		out string
+	}{
+		{
+			"",
+			"",
+		}, {
+			"icon",
+			"",
+		}, {
+			"icon icon_size_24 icon_snow",
+			"icon_snow",
+		}, {
+			"icon icon_size_24 icon_rain",
+			"icon_rain",
+		},
+	}
+
+	for _, item := range testData {
+		out := parseIcon(item.in)
+		if out != item.out {
+			t.Errorf("expected: %#v, real: %#v", item.out, out)
+		}
+	}
+}
+
+func Test_parseForecastNextAt(t *testing.T) {
+	now := time.Date(2021, 6, 28, 23, 30, 0, 0, time.UTC)
+	dataNextDays := map[string][]string{
+		"date":       {"2021-06-28", "2021-06-29", "2021-06-30", "2021-07-01"},
+		"desc":       {"Ясно", "Облачно", "Дождь", "Снег"},
+		"temp":       {"+25", "+24", "−1", "+20"},
+		"temp_night": {"+15", "+14", "−3", "+10"},
+	}
+
+	testData := []struct {
+		name      string
+		daysLimit int
+		out       []DayForecast
+	}{
+		{
+			name:      "skip today",
+			daysLimit: 10,
+			out: []DayForecast{
+				{DateHuman: "29.06 (вт)", Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14},
+				{DateHuman: "30.06 (ср)", Date: "2021-06-30", Desc: "дождь", Temp: -1, TempNight: -3},
+				{DateHuman: "01.07 (чт)", Date: "2021-07-01", Desc: "снег", Temp: 20, TempNight: 10},
+			},
+		}, {
+			name:      "with limit",
+			daysLimit: 1,
+			out: []DayForecast{
+				{DateHuman: "29.06 (вт)", Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14},
+			},
+		},
+	}
+
+	for _, item := range testData {
+		out := parseForecastNextAt(dataNextDays, item.daysLimit, now)
+		if !reflect.DeepEqual(out, item.out) {
+			t.Errorf("%q. expected: %#v, real: %#v", item.name, item.out, out)
+		}
+	}
+}
+
+func Test_parseForecastNextAtFeels(t *testing.T) {
+	now := time.Date(2021, 6, 28, 12, 0, 0, 0, time.UTC)
+	dataNextDays := map[string][]string{
+		"date":       {"2021-06-29", "2021-06-30"},
+		"desc":       {"Облачно", "Дождь"},
+		"temp":       {"+24", "−1"},
+		"temp_night": {"+14", "−3"},
+		"temp_feels": {"+22"},
+	}
+
+	feels := 22
+	expected := []DayForecast{
+		{DateHuman: "29.06 (вт)", Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14, TempFeels: &feels},
+		{DateHuman: "30.06 (ср)", Date: "2021-06-30", Desc: "дождь", Temp: -1, TempNight: -3},
+	}
+	if out := parseForecastNextAt(dataNextDays, 10, now); !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected: %#v, real: %#v", expected, out)
+	}
+}
+
+func Test_parseForecastNextAtUneven(t *testing.T) {
+	now := time.Date(2021, 6, 28, 12, 0, 0, 0, time.UTC)
+	dataNextDays := map[string][]string{
+		"date":       {"2021-06-29", "2021-06-30", "2021-07-01"},
+		"desc":       {"Облачно", "Дождь"},
+		"temp":       {"+24", "−1"},
+		"temp_night": {"+14", "+10"},
+	}
+
+	uneven := getUnevenColumns(dataNextDays)
+	if !reflect.DeepEqual(uneven, []string{"desc", "temp", "temp_night"}) {
+		t.Errorf("getUnevenColumns: expected: %#v, real: %#v", []string{"desc", "temp", "temp_night"}, uneven)
+	}
+
+	// missing values are marked as absent, not 0
+	expected := []DayForecast{
+		{DateHuman: "29.06 (вт)", Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14},
+		{DateHuman: "30.06 (ср)", Date: "2021-06-30", Desc: "дождь", Temp: -1, TempNight: 10},
+		{DateHuman: "01.07 (чт)", Date: "2021-07-01", Desc: "", noTemp: true, noTempNight: true},
+	}
+	out := parseForecastNextAt(dataNextDays, 10, now)
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected: %#v, real: %#v", expected, out)
+	}
+
+	// and printed as empty cells
+	for _, column := range []string{"desc", "temp", "temp_night"} {
+		if value := forecastValue(column, out[2], Config{}); value != "" {
+			t.Errorf("%q. expected empty cell, real: %#v", column, value)
+		}
+	}
+
+	// the same keys for each day in JSON
+	outJSON, err := json.Marshal(out)
+	expectedJSON := `[{"date":"2021-06-29","desc":"облачно","temp":24,"temp_night":14,"temp_feels":null},` +
+		`{"date":"2021-06-30","desc":"дождь","temp":-1,"temp_night":10,"temp_feels":null},` +
+		`{"date":"2021-07-01","desc":"","temp":null,"temp_night":null,"temp_feels":null}]`
+	if err != nil || string(outJSON) != expectedJSON {
+		t.Errorf("expected: %s, real: %s (%v)", expectedJSON, outJSON, err)
+	}
+
+	loaded := []DayForecast{}
+	if err := json.Unmarshal(outJSON, &loaded); err != nil || !reflect.DeepEqual(loaded, []DayForecast{
+		{Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14},
+		{Date: "2021-06-30", Desc: "дождь", Temp: -1, TempNight: 10},
+		{Date: "2021-07-01", noTemp: true, noTempNight: true},
+	}) {
+		t.Errorf("expected days with absent temperatures from JSON, real: %#v (%v)", loaded, err)
+	}
+}
+
+func Test_cacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yandex-weather-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cached := cachedWeather{
+		Time: time.Date(2021, 6, 28, 12, 0, 0, 0, time.UTC),
+		ForecastNow: map[string]interface{}{
+			"city":     "Погода в Москве",
+			"term_now": -3,
+		},
+		ByHours:  []HourTemp{{Hour: 17, Temp: -3, Icon: "icon_snow"}},
+		NextDays: []DayForecast{{DateHuman: "29.06 (вт)", Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14}},
+	}
+
+	fileName := filepath.Join(dir, "cache", "moscow.json")
+	if err := writeCacheFile(fileName, cached); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := readCacheFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, cached) {
+		t.Errorf("expected: %#v, real: %#v", cached, loaded)
+	}
+}
+
+func Test_pressureTrendFromCache(t *testing.T) {
+	now := time.Date(2030, 1, 2, 12, 0, 0, 0, time.UTC)
+	reading := func(pressure string, age time.Duration) pressureReading {
+		return pressureReading{Time: now.Add(-age), Pressure: pressure}
+	}
+	testData := []struct {
+		pressure  string
+		pressures []pressureReading
+		out       string
+	}{
+		{"745 мм рт. ст.", []pressureReading{reading("742 мм рт. ст.", time.Hour)}, "rising"},
+		{"745 мм рт. ст.", []pressureReading{reading("747 мм рт. ст.", 3*time.Hour)}, "falling"},
+		{"745 мм рт. ст.", []pressureReading{reading("745 мм рт. ст.", time.Hour)}, ""},
+		// the newest one which is older than an hour
+		{"745 мм рт. ст.", []pressureReading{reading("742 мм рт. ст.", 5*time.Hour), reading("745 мм рт. ст.", 2*time.Hour), reading("747 мм рт. ст.", 70*time.Minute)}, "falling"},
+		{"745 мм рт. ст.", []pressureReading{reading("742 мм рт. ст.", 2*time.Hour), reading("745 мм рт. ст.", 5*time.Minute)}, "rising"},
+		{"745 мм рт. ст.", []pressureReading{reading("742 мм рт. ст.", 30*time.Minute)}, ""},
+		{"745 мм рт. ст.", []pressureReading{reading("742 мм рт. ст.", 13*time.Hour)}, ""},
+		{"745 мм рт. ст.", []pressureReading{reading("", 2*time.Hour)}, ""},
+		{"", []pressureReading{reading("742 мм рт. ст.", 2*time.Hour)}, ""},
+		{"745 мм рт. ст.", nil, ""},
+	}
+
+	for i, item := range testData {
+		if out := pressureTrendFromCache(map[string]interface{}{"pressure": item.pressure}, cachedWeather{Pressures: item.pressures}, now); out != item.out {
+			t.Errorf("%d. expected: %#v, real: %#v", i, item.out, out)
+		}
+	}
+}
+
+func Test_addPressureReading(t *testing.T) {
+	now := time.Date(2030, 1, 2, 12, 0, 0, 0, time.UTC)
+	reading := func(pressure string, age time.Duration) pressureReading {
+		return pressureReading{Time: now.Add(-age), Pressure: pressure}
+	}
+	testData := []struct {
+		pressures []pressureReading
+		pressure  string
+		out       []pressureReading
+	}{
+		{nil, "745", []pressureReading{reading("745", 0)}},
+		{nil, "", []pressureReading{}},
+		// not more often than PressureReadingInterval
+		{[]pressureReading{reading("744", 5*time.Minute)}, "745", []pressureReading{reading("744", 5*time.Minute)}},
+		{[]pressureReading{reading("744", 10*time.Minute)}, "745", []pressureReading{reading("744", 10*time.Minute), reading("745", 0)}},
+		// old ones are removed
+		{[]pressureReading{reading("740", 13*time.Hour), reading("744", time.Hour)}, "745", []pressureReading{reading("744", time.Hour), reading("745", 0)}},
+	}
+
+	for i, item := range testData {
+		if out := addPressureReading(item.pressures, item.pressure, now); !reflect.DeepEqual(out, item.out) {
+			t.Errorf("%d. expected: %#v, real: %#v", i, item.out, out)
+		}
+	}
+}
+
+func Test_getWeatherPageConditional(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yandex-weather-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"XDG_CACHE_HOME", "HOME"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, dir)
+	}
+
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "<html><head><title>Погода в Москве</title></head></html>")
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		doc, _ := getWeatherPage(context.Background(), server.URL+"/moscow", Config{})
+		title, err := doc.GetDataSingle("title")
+		if err != nil || title != "Погода в Москве" {
+			t.Errorf("%d. expected title from page, real: %q (%v)", i, title, err)
+		}
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("expected: 2 requests with 1 not modified, real: %d requests with %d not modified", requests, notModified)
+	}
+}
+
+func Test_getWeatherWttr(t *testing.T) {
+	// wttr.in forecast starts from today
+	day := func(days int) time.Time {
+		return time.Now().AddDate(0, 0, days)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/moscow" || r.URL.Query().Get("format") != "j1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{
+			"current_condition": [{"temp_C": "-3", "humidity": "75", "pressure": "1013", "windspeedKmph": "13", "winddirDegree": "270",
+				"lang_ru": [{"value": "Облачно"}]}],
+			"nearest_area": [{"areaName": [{"value": "Moscow"}]}],
+			"weather": [
+				{"date": "%s", "maxtempC": "25", "mintempC": "15", "hourly": []},
+				{"date": "%s", "maxtempC": "24", "mintempC": "14", "hourly": [{"time": "0", "lang_ru": [{"value": "Ясно"}]}, {"time": "1200", "lang_ru": [{"value": "Облачно"}]}]},
+				{"date": "%s", "maxtempC": "-1", "mintempC": "-3", "hourly": [{"time": "1200", "lang_ru": [{"value": "Дождь"}]}]},
+				{"date": "%s", "maxtempC": "20", "mintempC": "10", "hourly": []}
+			]
+		}`, day(0).Format("2006-01-02"), day(1).Format("2006-01-02"), day(2).Format("2006-01-02"), day(3).Format("2006-01-02"))
+	}))
+	defer server.Close()
+
+	defer os.Setenv(EnvBaseURLWttrName, os.Getenv(EnvBaseURLWttrName))
+	os.Setenv(EnvBaseURLWttrName, server.URL+"/")
+
+	forecastNow, forecastByHours, forecastNext, err := getWeatherWttr(context.Background(), Config{city: "moscow", skipDays: 1, daysLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedNow := map[string]interface{}{
+		"city":       "Moscow",
+		"source_url": server.URL + "/moscow?format=j1&lang=ru",
+		"term_now":   -3,
+		"desc_now":   "Облачно",
+		"humidity":   "75%",
+		"pressure":   "760 мм рт. ст.",
+		"wind":       "3,6 м/с, З",
+
+		"next_days_on_page": true,
+	}
+	if !reflect.DeepEqual(forecastNow, expectedNow) {
+		t.Errorf("expected: %#v, real: %#v", expectedNow, forecastNow)
+	}
+	if len(forecastByHours) != 0 {
+		t.Errorf("expected empty forecast by hours, real: %#v", forecastByHours)
+	}
+	expectedNext := []DayForecast{{Desc: "дождь", Temp: -1, TempNight: -3}}
+	expectedNext[0].DateHuman, expectedNext[0].Date = formatDates(day(2))
+	if !reflect.DeepEqual(forecastNext, expectedNext) {
+		t.Errorf("expected: %#v, real: %#v", expectedNext, forecastNext)
+	}
+
+	if _, _, _, err := getWeatherWttr(context.Background(), Config{city: "unknown"}); err == nil {
+		t.Errorf("expected error for not found city")
+	}
+}
+
+func Test_compactJSON(t *testing.T) {
+	testData := []struct {
+		in, out string
+	}{
+		{`{"city":"Москва","desc_now":"","term_now":0}`, `{"city":"Москва","term_now":0}`},
+		{`{"b":1,"a":"","c":null,"d":{"e":""}}`, `{"b":1}`},
+		{`{"by_hours":[{"hour":17,"icon":""}],"next_days":[]}`, `{"by_hours":[{"hour":17}],"next_days":[]}`},
+		{`[{"query":"kiev","error":""},{"query":"","error":"not found"}]`, `[{"query":"kiev"},{"error":"not found"}]`},
+		{`{"a":"\u003cb\u003e","f":false,"n":-1.5}`, `{"a":"\u003cb\u003e","f":false,"n":-1.5}`},
+	}
+
+	for _, item := range testData {
+		out, err := compactJSON([]byte(item.in))
+		if err != nil || string(out) != item.out {
+			t.Errorf("%s. expected: %#v, real: %#v (%v)", item.in, item.out, string(out), err)
+		}
+	}
+
+	if _, err := compactJSON([]byte(`{"a":`)); err == nil {
+		t.Errorf("expected error for invalid JSON")
+	}
+}
+
+func Test_headerFlags(t *testing.T) {
+	testData := []struct {
+		in  string
+		ok  bool
+		key string
+		out string
+	}{
+		{"Accept-Language: en", true, "Accept-Language", "en"},
+		{"cookie:yandexuid=1; i=2", true, "Cookie", "yandexuid=1; i=2"},
+		{"X-Empty:", true, "X-Empty", ""},
+		{"Accept-Language en", false, "", ""},
+		{": en", false, "", ""},
+		{"Bad Key: value", false, "", ""},
+	}
+
+	for _, item := range testData {
+		headers := headerFlags{}
+		err := headers.Set(item.in)
+		if (err == nil) != item.ok {
+			t.Errorf("%q. expected ok: %v, real error: %v", item.in, item.ok, err)
+			continue
+		}
+		if item.ok && http.Header(headers).Get(item.key) != item.out {
+			t.Errorf("%q. expected: %#v, real: %#v", item.in, item.out, http.Header(headers).Get(item.key))
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><head><title>%s|%s</title></head></html>", r.Header.Get("Accept-Language"), r.Header.Get("User-Agent"))
+	}))
+	defer server.Close()
+
+	headers := headerFlags{}
+	for _, header := range []string{"Accept-Language: en", "User-Agent: test"} {
+		if err := headers.Set(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doc, _ := getWeatherPage(context.Background(), server.URL+"/moscow", Config{headers: headers})
+	if title, err := doc.GetDataSingle("title"); err != nil || title != "en|test" {
+		t.Errorf("expected headers from -header in request, real: %q (%v)", title, err)
+	}
+}
+
+func Test_getBriefJSON(t *testing.T) {
+	forecastNext := []DayForecast{
+		{DateHuman: "29.06 (вт)", Date: "2021-06-29", Desc: "облачно", Temp: 24, TempNight: 14},
+		{DateHuman: "30.06 (ср)", Date: "2021-06-30", Desc: "дождь", Temp: -1, TempNight: -3},
+	}
+
+	testData := []struct {
+		forecastNow  map[string]interface{}
+		forecastNext []DayForecast
+		out          string
+	}{
+		{
+			map[string]interface{}{"city": "Москва", "term_now": 20, "feels_now": 18},
+			forecastNext,
+			`{"city":"Москва","term_now":20,"feels_now":18,"date":"2021-06-29","temp_day":24,"temp_night":14}`,
+		},
+		{
+			map[string]interface{}{"city": "Москва", "term_now": 0, "feels_now": ""},
+			nil,
+			`{"city":"Москва","term_now":0,"temp_day":null,"temp_night":null}`,
+		},
+		{
+			map[string]interface{}{"city": "Москва", "term_now": 20},
+			[]DayForecast{{Date: "2021-06-29", Temp: 24, noTempNight: true}},
+			`{"city":"Москва","term_now":20,"date":"2021-06-29","temp_day":24,"temp_night":null}`,
+		},
+	}
+
+	for i, item := range testData {
+		out, err := json.Marshal(getBriefJSON(item.forecastNow, item.forecastNext))
+		if err != nil || string(out) != item.out {
+			t.Errorf("%d. expected: %s, real: %s (%v)", i, item.out, out, err)
+		}
+	}
+}
+
+func Test_getForecastJSONMeta(t *testing.T) {
+	forecastNow := map[string]interface{}{
+		"city":            "Москва",
+		"term_now":        20,
+		"selectors_found": []string{"city", "term_now"},
+		// from JSON of cache
+		"selectors_empty": []interface{}{"humidity", "next_days.temp_feels"},
+	}
+
+	out, err := json.Marshal(getForecastJSON(forecastNow, nil, nil, Config{meta: true, noToday: true}))
+	expected := `{"city":"Москва","source_url":"","term_now":20,"temp_unit":"celsius",` +
+		`"_meta":{"found":["city","term_now"],"empty":["humidity","next_days.temp_feels"]}}`
+	if err != nil || string(out) != expected {
+		t.Errorf("expected: %s, real: %s (%v)", expected, out, err)
+	}
+}
+
+func Test_getForecastJSONSuppressed(t *testing.T) {
+	forecastNow := map[string]interface{}{
+		"city":      "Москва",
+		"term_now":  20,
+		"pressure":  "745 мм рт. ст.",
+		"humidity":  "75%",
+		"wind":      "3,5 м/с, З",
+		"wind_gust": "8 м/с",
+	}
+
+	out, err := json.Marshal(getForecastJSON(forecastNow, nil, nil, Config{noPressure: true, noWind: true, noToday: true}))
+	expected := `{"city":"Москва","source_url":"","term_now":20,"humidity":"75%","temp_unit":"celsius"}`
+	if err != nil || string(out) != expected {
+		t.Errorf("expected: %s, real: %s (%v)", expected, out, err)
+	}
+
+	out, err = json.Marshal(getForecastJSON(forecastNow, nil, nil, Config{noHumidity: true, comfort: true, noToday: true}))
+	expected = `{"city":"Москва","source_url":"","term_now":20,"pressure":"745 мм рт. ст.","wind":"3,5 м/с, З","wind_gust":"8 м/с","temp_unit":"celsius"}`
+	if err != nil || string(out) != expected {
+		t.Errorf("expected: %s, real: %s (%v)", expected, out, err)
+	}
+}
+
+func Test_marshalJSONStableOrder(t *testing.T) {
+	forecastNow := map[string]interface{}{
+		"wind":       "3,5 м/с, З",
+		"humidity":   "75%",
+		"pressure":   "745 мм рт. ст.",
+		"desc_now":   "Ясно",
+		"term_now":   20,
+		"city":       "Москва",
+		"source_url": "https://yandex.ru/pogoda/moscow",
+		"wind_gust":  "8 м/с",
+	}
+	feels := -5
+	forecastNext := []DayForecast{{Date: "2030-01-02", Desc: "снег", Temp: -2, TempNight: -6, TempFeels: &feels}}
+	expected := `{"city":"Москва","source_url":"https://yandex.ru/pogoda/moscow","term_now":20,"desc_now":"Ясно","pressure":"745 мм рт. ст.",` +
+		`"humidity":"75%","wind":"3,5 м/с, З","wind_gust":"8 м/с","temp_unit":"celsius",` +
+		`"next_days":[{"date":"2030-01-02","desc":"снег","temp":-2,"temp_night":-6,"temp_feels":-5}]}`
+
+	for _, cfg := range []Config{{noToday: true}, {noToday: true, compactJSON: true}} {
+		for i := 0; i < 10; i++ {
+			if out := string(cfg.marshalJSON(getForecastJSON(forecastNow, nil, forecastNext, cfg))); out != expected {
+				t.Fatalf("expected: %s, real: %s", expected, out)
+			}
+		}
+	}
+}
+
+func Test_getEmptyCriticalSelectors(t *testing.T) {
+	testData := []struct {
+		in  map[string]interface{}
+		out []string
+	}{
+		{map[string]interface{}{}, []string{}},
+		{map[string]interface{}{"selectors_empty": []string{"feels_now", "wind_gust", "next_days.temp_feels"}}, []string{}},
+		{map[string]interface{}{"selectors_empty": []string{"feels_now", "humidity", "next_days.temp"}}, []string{"humidity", "next_days.temp"}},
+		// from JSON of cache
+		{map[string]interface{}{"selectors_empty": []interface{}{"pressure"}}, []string{"pressure"}},
+	}
+
+	for i, item := range testData {
+		if out := getEmptyCriticalSelectors(item.in); !reflect.DeepEqual(out, item.out) {
+			t.Errorf("%d. expected: %#v, real: %#v", i, item.out, out)
+		}
+	}
+}
+
+func Test_flagHelp(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.Int("days", 10, "maximum days to show")
+	flagSet.Int("skip", 0, "skip first days in forecast")
+	flagSet.String("theme", "dark", "color theme")
+	flagSet.String("file", "", "parse weather from `FILE`")
+	flagSet.Bool("json", false, "get JSON")
+	flagSet.Duration("timeout", 0, "timeout of request")
+	flagSet.Duration("deadline", time.Minute, "timeout of all requests")
+	flagSet.Var(headerFlags{}, "header", "add HTTP header")
+
+	expected := bytes.Buffer{}
+	flagSet.SetOutput(&expected)
+	flagSet.PrintDefaults()
+
+	out := ""
+	flagSet.VisitAll(func(f *flag.Flag) {
+		out += flagHelp(f)
+	})
+	if out != expected.String() {
+		t.Errorf("expected: %q, real: %q", expected.String(), out)
+	}
+}
+
+func Test_printHelpAll(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.Bool("json", false, "get JSON")
+	flagSet.Bool("new-option", false, "option without group")
+
+	out := bytes.Buffer{}
+	printHelpAll(&out, flagSet, "yandex-weather-cli")
+	for _, expected := range []string{
+		"Usage: yandex-weather-cli [options] [city]\n",
+		"\nJSON and other formats:\n  -json\n    \tget JSON\n  examples:\n    yandex-weather-cli -json -json-indent london\n",
+		"\nOther:\n  -new-option\n    \toption without group\n  examples:\n    yandex-weather-cli -verbose -timing london\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected: %q in output, real: %q", expected, out.String())
+		}
+	}
+}
+
+func Test_runDryRun(t *testing.T) {
+	cfg := Config{baseURL: "https://yandex.ru/pogoda/", baseURLMini: "https://p.ya.ru/", city: "nizhny-novgorod", headers: headerFlags{"Accept": {"text/html"}}}
+	out := bytes.Buffer{}
+	expected := "GET https://yandex.ru/pogoda/nizhny-novgorod\n  Accept: text/html\n  User-Agent: " + userAgent + "\n" +
+		"GET https://p.ya.ru/nizhny-novgorod\n  Accept: text/html\n  User-Agent: " + userAgent + "\n"
+	if exitCode := runDryRun(&out, cfg); exitCode != 0 || out.String() != expected {
+		t.Errorf("expected: %q, real: %q (%d)", expected, out.String(), exitCode)
+	}
+
+	citiesFile, err := ioutil.TempFile("", "cities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(citiesFile.Name())
+	if _, err := citiesFile.WriteString("spb\n# comment\nhttps://yandex.ru/pogoda/kazan?via=srp\n"); err != nil {
+		t.Fatal(err)
+	}
+	citiesFile.Close()
+
+	out.Reset()
+	cfg.citiesFile, cfg.noToday, cfg.headers = citiesFile.Name(), true, headerFlags{}
+	expected = "GET https://yandex.ru/pogoda/spb\n  User-Agent: " + userAgent + "\n\nGET https://yandex.ru/pogoda/kazan\n  User-Agent: " + userAgent + "\n"
+	if exitCode := runDryRun(&out, cfg); exitCode != 0 || out.String() != expected {
+		t.Errorf("expected: %q, real: %q (%d)", expected, out.String(), exitCode)
+	}
+}
+
+func Test_retryBudget(t *testing.T) {
+	var unlimited *retryBudget
+	if !unlimited.take() {
+		t.Errorf("expected retry without budget")
+	}
+
+	budget := &retryBudget{left: 5}
+	taken := make(chan bool, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			taken <- budget.take()
+		}()
+	}
+	wg.Wait()
+	close(taken)
+
+	count := 0
+	for ok := range taken {
+		if ok {
+			count++
+		}
+	}
+	if count != 5 || budget.left != 0 {
+		t.Errorf("expected 5 retries, real: %d, left: %d", count, budget.left)
+	}
+}
+
+func Test_getPageRetryBudget(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageURL := "http://" + listener.Addr().String() + "/moscow"
+	listener.Close()
+
+	cfg := Config{retries: 5, retryBudget: &retryBudget{left: 1}}
+	doc, _ := getPage(context.Background(), pageURL, cfg)
+	if doc.Err == nil || !strings.Contains(doc.Err.Error(), "-max-retries-total is exhausted") || !isNetworkError(doc.Err) || cfg.retryBudget.left != 0 {
+		t.Errorf("expected network error after one retry, real: %v, left: %d", doc.Err, cfg.retryBudget.left)
+	}
+}
+
+func Test_getPageRetryStatus(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 || r.URL.Path == "/unavailable" {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(map[string]int{"/moscow": http.StatusTooManyRequests, "/unavailable": http.StatusServiceUnavailable}[r.URL.Path])
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><div class="fact"><div class="fact__temp"><span class="temp__value">-3</span></div></div></body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := Config{retries: 2, retryStatus: []int{429, 503}}
+	doc, _ := getPage(context.Background(), server.URL+"/moscow", cfg)
+	if doc.Err != nil || requests != 2 {
+		t.Errorf("expected page after one retry, real: %v, requests: %d", doc.Err, requests)
+	}
+
+	requests = 0
+	doc, _ = getPage(context.Background(), server.URL+"/unavailable", cfg)
+	if !errors.Is(doc.Err, errUnavailable) || requests != 3 {
+		t.Errorf("expected unavailable error after all retries, real: %v, requests: %d", doc.Err, requests)
+	}
+
+	requests = 0
+	doc, _ = getPage(context.Background(), server.URL+"/unavailable", Config{retries: 2})
+	if !errors.Is(doc.Err, errUnavailable) || requests != 1 {
+		t.Errorf("expected unavailable error without retries, real: %v, requests: %d", doc.Err, requests)
+	}
+}
+
+func Test_flatForecastJSON(t *testing.T) {
+	feels := -7
+	forecast := ForecastJSON{
+		City:           "Москва",
+		CurrentWeather: &CurrentWeather{TermNow: -3},
+		TempUnit:       "celsius",
+		NextDays:       []DayForecast{{Date: "2030-01-02", Desc: "снег", Temp: -2, TempNight: -6, TempFeels: &feels}, {Date: "2030-01-03", Desc: "ясно", Temp: 1, TempNight: -4}},
+	}
+
+	out, err := json.Marshal(flatForecastJSON(forecast))
+	expected := `{"city":"Москва","source_url":"","term_now":-3,"temp_unit":"celsius",` +
+		`"day1_date":"2030-01-02","day1_desc":"снег","day1_temp":-2,"day1_temp_night":-6,"day1_temp_feels":-7,` +
+		`"day2_date":"2030-01-03","day2_desc":"ясно","day2_temp":1,"day2_temp_night":-4,"day2_temp_feels":null}`
+	if err != nil || string(out) != expected {
+		t.Errorf("expected: %s, real: %s (%v)", expected, out, err)
+	}
+	if len(forecast.NextDays) != 2 {
+		t.Errorf("expected forecast without changes, real: %#v", forecast.NextDays)
+	}
+
+	out, err = json.Marshal(flatForecastJSON(ForecastJSON{City: "Москва"}))
+	if expected := `{"city":"Москва","source_url":"","temp_unit":""}`; err != nil || string(out) != expected {
+		t.Errorf("expected: %s, real: %s (%v)", expected, out, err)
+	}
+}
+
+func Test_getWeatherRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/spb", http.RedirectHandler("/saint-petersburg", http.StatusFound))
+	mux.HandleFunc("/saint-petersburg", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Погода в Санкт-Петербурге</title></head><body>
+			<div class="fact"><div class="fact__temp"><span class="temp__value">+20</span></div><div class="link__condition">Ясно</div></div>
+		</body></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := Config{baseURL: server.URL + "/", city: "spb", noToday: true}
+	forecastNow, _, _, err := getWeather(context.Background(), cfg)
+	expected := server.URL + "/saint-petersburg"
+	if err != nil || forecastNow["source_url"] != expected || weatherLink(forecastNow, cfg) != expected {
+		t.Errorf("expected: %s, real: %#v, %s (%v)", expected, forecastNow["source_url"], weatherLink(forecastNow, cfg), err)
+	}
+	if out, err := json.Marshal(getForecastJSON(forecastNow, nil, nil, cfg)); err != nil || !strings.Contains(string(out), `"source_url":"`+expected+`"`) {
+		t.Errorf("expected source_url %s in JSON, real: %s (%v)", expected, out, err)
+	}
+
+	if link := weatherLink(map[string]interface{}{}, cfg); link != server.URL+"/spb" {
+		t.Errorf("expected requested URL without source_url, real: %s", link)
+	}
+}
+
+func Test_renderError(t *testing.T) {
+	if err := render(map[string]interface{}{"city": ""}, nil, nil, Config{city: "abc"}); err == nil || err.Error() != `City "abc" not found` {
+		t.Errorf("expected error about not found city, real: %v", err)
+	}
+
+	tmpl, err := (Config{}).parseTemplate("{{.City}} {{.TermNow}} {{.DescNow}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without current weather: empty values
+	if err := render(map[string]interface{}{"city": "Москва", "term_now": 5}, nil, nil, Config{noCurrent: true, outputTemplate: tmpl}); err != nil {
+		t.Errorf("expected template without current weather, real error: %v", err)
+	}
+
+	tmpl, err = (Config{}).parseTemplate("{{.Unknown}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := render(map[string]interface{}{"city": "Москва"}, nil, nil, Config{outputTemplate: tmpl}); err == nil {
+		t.Errorf("expected error of template")
+	}
+}
+
+func Test_getWeatherCoordsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cfg := Config{baseURL: server.URL + "/", city: "?lat=0&lon=-160", coords: "0,-160", noToday: true}
+	_, _, _, err := getWeather(context.Background(), cfg)
+	expected := "no weather page for coordinates 0,-160 (" + server.URL + "/?lat=0&lon=-160)"
+	if err == nil || err.Error() != expected || !errors.Is(err, errPageNotFound) {
+		t.Errorf("expected: %s, real: %v", expected, err)
+	}
+}
+
+func Test_getWeatherNotEmpty(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			fmt.Fprint(w, `<html><head><title>Погода в Москве</title></head><body><div class="fact"></div></body></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html><head><title>Погода в Москве</title></head><body>
+			<div class="fact"><div class="fact__temp"><span class="temp__value">+20</span></div><div class="link__condition">Ясно</div></div>
+		</body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := Config{baseURL: server.URL + "/", city: "moscow", noToday: true, retryOnEmpty: 2}
+	forecastNow, _, _, err := getWeatherNotEmpty(context.Background(), cfg)
+	if err != nil || requests != 2 || forecastNow["desc_now"] != "Ясно" || forecastNow["term_now"] != 20 {
+		t.Errorf("expected weather from the second request, real: %d requests, %#v (%v)", requests, forecastNow, err)
+	}
+
+	requests, cfg.retryOnEmpty = 0, 0
+	if forecastNow, _, _, err := getWeatherNotEmpty(context.Background(), cfg); err != nil || requests != 1 || !isEmptyForecast(forecastNow, nil) {
+		t.Errorf("expected empty weather without -retry-on-empty, real: %d requests, %#v (%v)", requests, forecastNow, err)
+	}
+
+	requests, cfg.strict = 1, true
+	expected := "empty fields on the page (-strict): humidity, next_days.date, next_days.desc, next_days.temp, next_days.temp_night, pressure, wind"
+	if _, _, _, err := getWeatherNotEmpty(context.Background(), cfg); err == nil || err.Error() != expected {
+		t.Errorf("expected: %s, real: %v", expected, err)
+	}
+}
+
+func Test_getWeatherStrictUneven(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Погода в Москве</title></head><body>
+			<div class="fact"><div class="fact__temp"><span class="temp__value">+20</span></div><div class="link__condition">Ясно</div>
+			<div class="fact__props"><div class="fact__pressure">745 мм рт. ст.</div><div class="fact__humidity">75%</div><div class="fact__wind-speed">3 м/с</div></div></div>
+			<div class="forecast-briefly__days">
+				<div><time class="time" datetime="2030-01-02">2 января</time><div class="forecast-briefly__condition">Снег</div>
+					<div class="forecast-briefly__temp_day"><span class="temp__value">−2</span></div>
+					<div class="forecast-briefly__temp_night"><span class="temp__value">−6</span></div></div>
+				<div><time class="time" datetime="2030-01-03">3 января</time><div class="forecast-briefly__condition">Ясно</div>
+					<div class="forecast-briefly__temp_day"><span class="temp__value">+1</span></div></div>
+			</div>
+		</body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := Config{baseURL: server.URL + "/", city: "moscow", noToday: true, strict: true, daysLimit: 10}
+	_, _, _, err := getWeatherNotEmpty(context.Background(), cfg)
+	expected := "empty fields on the page (-strict): next_days.temp_night"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected: %s, real: %v", expected, err)
+	}
+}
+
+func Test_isForceColor(t *testing.T) {
+	testData := map[string]bool{
+		"":      false,
+		"0":     false,
+		"false": false,
+		"FALSE": false,
+		"1":     true,
+		"true":  true,
+		"3":     true,
+	}
+
+	for in, expected := range testData {
+		if real := isForceColor(in); real != expected {
+			t.Errorf("%q: expected: %#v, real: %#v", in, expected, real)
+		}
+	}
+}
+
+func Test_saveSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yandex-weather-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := Config{baseURL: "http://localhost/pogoda/", baseURLMini: "http://localhost/mini/", city: "moscow"}
+	snapshot := &pageSnapshot{dir: dir}
+	snapshot.add(cfg.baseURL+cfg.city, []byte("<html>empty</html>"))
+	snapshot.add(cfg.baseURL+cfg.city, []byte("<html>page</html>"))
+	(*pageSnapshot)(nil).add(cfg.baseURLMini+cfg.city, []byte("<html>mini</html>"))
+
+	forecastNow := map[string]interface{}{"city": "Москва", "term_now": -3}
+	snapshotDir, err := saveSnapshot(snapshot, forecastNow, nil, nil, cfg, time.Date(2030, 1, 2, 3, 4, 5, 0, time.Local))
+	if expected := filepath.Join(dir, "moscow-20300102-030405"); err != nil || snapshotDir != expected {
+		t.Fatalf("expected: %s, real: %s (%v)", expected, snapshotDir, err)
+	}
+
+	if page, err := ioutil.ReadFile(filepath.Join(snapshotDir, "page.html")); err != nil || string(page) != "<html>page</html>" {
+		t.Errorf("expected the last page, real: %q (%v)", page, err)
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, "mini.html")); !os.IsNotExist(err) {
+		t.Errorf("expected no mini.html without page, real: %v", err)
+	}
+
+	weather := ForecastJSON{}
+	content, err := ioutil.ReadFile(filepath.Join(snapshotDir, "weather.json"))
+	if err == nil {
+		err = json.Unmarshal(content, &weather)
+	}
+	if err != nil || weather.City != "Москва" || weather.CurrentWeather == nil || weather.CurrentWeather.TermNow != -3 {
+		t.Errorf("expected parsed weather in JSON, real: %s (%v)", content, err)
+	}
+}
+
+func Test_getWeatherNextDaysOnPage(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		days := ""
+		if r.URL.Path == "/moscow" {
+			days = `<div class="forecast-briefly__days"><time class="time" datetime="` + tomorrow + ` 00:00"></time>
+				<div class="forecast-briefly__condition">Снег</div>
+				<div class="forecast-briefly__temp_day"><span class="temp__value">-2</span></div>
+				<div class="forecast-briefly__temp_night"><span class="temp__value">-6</span></div></div>`
+		}
+		fmt.Fprint(w, `<html><head><title>Погода</title></head><body>
+			<div class="fact"><div class="fact__temp"><span class="temp__value">-3</span></div><div class="link__condition">Снег</div></div>`+days+`
+		</body></html>`)
+	}))
+	defer server.Close()
+
+	testData := []struct {
+		city     string
+		skipDays int
+		days     int
+		onPage   bool
+	}{
+		{"moscow", 0, 1, true},
+		// all days are skipped, but the page has forecast
+		{"moscow", 1, 0, true},
+		{"london", 0, 0, false},
+	}
+
+	for _, item := range testData {
+		cfg := Config{baseURL: server.URL + "/", city: item.city, noToday: true, daysLimit: 10, skipDays: item.skipDays}
+		forecastNow, _, forecastNext, err := getWeather(context.Background(), cfg)
+		if err != nil || len(forecastNext) != item.days || hasNextDaysOnPage(forecastNow) != item.onPage {
+			t.Errorf("%s, skip %d: expected: %d days, %v, real: %d days, %v (%v)", item.city, item.skipDays, item.days, item.onPage, len(forecastNext), hasNextDaysOnPage(forecastNow), err)
+		}
+	}
+}